@@ -17,6 +17,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -25,11 +26,14 @@ import (
 	"os/signal"
 	"path/filepath"
 	"syscall"
+
+	"github.com/Mellanox/ipoib-cni/pkg/ipoib"
 )
 
 const (
 	defaultCNIBinDir    = "/host/opt/cni/bin"
 	defaultIPoIBBinFile = "/usr/bin/ipoib"
+	defaultNetnsDir     = "/var/run/netns"
 )
 
 func usage() {
@@ -40,14 +44,19 @@ func usage() {
 			"./entrypoint\n"+
 			"\t-h --help\n"+
 			"\t--cni-bin-dir=%s\n"+
-			"\t--ipoib-bin-file=%s\n",
-		defaultCNIBinDir, defaultIPoIBBinFile)
+			"\t--ipoib-bin-file=%s\n"+
+			"\t--reconcile-mtu=true\n"+
+			"\t--netns-dir=%s\n",
+		defaultCNIBinDir, defaultIPoIBBinFile, defaultNetnsDir)
 }
 
 func run() int {
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	cniBinDir := fs.String("cni-bin-dir", defaultCNIBinDir, "CNI binary destination directory")
 	ipoibBinFile := fs.String("ipoib-bin-file", defaultIPoIBBinFile, "Source ipoib binary path")
+	reconcileMTU := fs.Bool("reconcile-mtu", true, "Propagate master MTU changes to the pod children which "+
+		"inherited the master MTU")
+	netnsDir := fs.String("netns-dir", defaultNetnsDir, "Directory of the pod network namespaces")
 	fs.Usage = usage
 	err := fs.Parse(os.Args[1:])
 	if err != nil {
@@ -86,10 +95,17 @@ func run() int {
 	}
 
 	fmt.Println("IPoIB CNI binary installed, waiting for termination signal.")
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
-	defer signal.Stop(ch)
-	<-ch
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	if *reconcileMTU {
+		go func() {
+			// the binary stays installed without the reconciler, so keep running
+			if err := ipoib.NewMTUReconciler(*netnsDir).Run(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "MTU reconciler stopped: %v\n", err)
+			}
+		}()
+	}
+	<-ctx.Done()
 	return 0
 }
 
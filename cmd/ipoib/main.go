@@ -17,13 +17,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 
+	"github.com/containernetworking/cni/pkg/invoke"
 	"github.com/containernetworking/cni/pkg/skel"
 	cniTypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
@@ -37,7 +44,11 @@ import (
 
 	"github.com/Mellanox/ipoib-cni/pkg/config"
 	"github.com/Mellanox/ipoib-cni/pkg/ipoib"
+	"github.com/Mellanox/ipoib-cni/pkg/journal"
+	"github.com/Mellanox/ipoib-cni/pkg/logging"
+	"github.com/Mellanox/ipoib-cni/pkg/state"
 	"github.com/Mellanox/ipoib-cni/pkg/types"
+	"github.com/Mellanox/ipoib-cni/pkg/validate"
 )
 
 const (
@@ -50,6 +61,13 @@ var (
 	date    = "unknown date"
 )
 
+var (
+	// newManager, stateDir and journalDir are replaced by the tests
+	newManager = ipoib.NewIpoibManager
+	stateDir   = state.Dir
+	journalDir = journal.Dir
+)
+
 //nolint:gochecknoinits
 func init() {
 	runtime.LockOSThread()
@@ -60,6 +78,18 @@ func cmdAdd(args *skel.CmdArgs) error {
 	if err != nil {
 		return err
 	}
+	if err = initLogging(n); err != nil {
+		return err
+	}
+	if err = config.ApplyOverrides(n, args.Args); err != nil {
+		return err
+	}
+	if err = config.LoadPolicy(n, args.Args); err != nil {
+		return err
+	}
+	if err = config.ValidateIfName(args.IfName); err != nil {
+		return fmt.Errorf("invalid CNI_IFNAME: %v", err)
+	}
 
 	isIpamProvided := n.IPAM.Type != ""
 
@@ -69,9 +99,36 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 	defer func() { _ = netns.Close() }()
 
-	ipoibManager := ipoib.NewIpoibManager()
+	// A retried ADD reports the child of the previous one, which already ran IPAM and configured it. Nothing
+	// is created, so nothing is undone on failure either.
+	ipoibManager := newManager()
+	store := state.New(stateDir)
+	attachment, err := store.Load(args.ContainerID, args.IfName)
+	if err != nil {
+		slog.Warn("ignoring unreadable attachment state", "error", err)
+	}
+	recordedMaster := ""
+	if attachment != nil {
+		recordedMaster = attachment.Master
+	}
+	reused, err := ipoibManager.ReuseIpoibLink(n, args.IfName, netns, recordedMaster)
+	if err != nil {
+		return err
+	}
+	if reused != nil {
+		return reportReused(store, args, n, attachment, reused, cniVersion)
+	}
+
+	jrnl := journal.New(journalDir)
+	intent := &journal.Intent{ContainerID: args.ContainerID, IfName: args.IfName, Netns: args.Netns,
+		Args: args.Args, Path: args.Path, Netconf: args.StdinData, IPAMType: n.IPAM.Type}
+	if err = jrnl.Begin(intent); err != nil {
+		return err
+	}
+	// Once ADD returns, its steps are either complete or undone
+	defer func() { _ = jrnl.Finish(intent) }()
 
-	ibLink, err := ipoibManager.CreateIpoibLink(n, args.IfName, netns)
+	ibLink, err := ipoibManager.CreateIpoibLink(n, args.ContainerID, args.IfName, netns, intent)
 	if err != nil {
 		return err
 	}
@@ -85,14 +142,25 @@ func cmdAdd(args *skel.CmdArgs) error {
 		}
 	}()
 
+	if err = saveAttachment(store, args, n, ibLink, nil); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = store.Delete(args.ContainerID, args.IfName)
+		}
+	}()
+
+	warnMembership(args, n, ibLink)
+
 	// Assume L2 interface only
-	result := &current.Result{CNIVersion: cniVersion, Interfaces: []*current.Interface{ibLink}}
+	result := &current.Result{CNIVersion: cniVersion, Interfaces: []*current.Interface{ibLink.Interface}}
 
 	if isIpamProvided {
 		if n.IPAM.Type == dhcpType {
 			return fmt.Errorf("ipam dhcp type is not supported")
 		}
-		err = handleIpamConfig(n, args, netns, result)
+		err = handleIpamConfig(n, args, netns, result, intent)
 		if err != nil {
 			return err
 		}
@@ -101,7 +169,7 @@ func cmdAdd(args *skel.CmdArgs) error {
 		err = netns.Do(func(_ ns.NetNS) error {
 			ipoibInterfaceLink, innerErr := netlink.LinkByName(args.IfName)
 			if innerErr != nil {
-				return fmt.Errorf("failed to find interface name %q: %v", ibLink.Name, innerErr)
+				return fmt.Errorf("failed to find interface name %q: %v", ibLink.Interface.Name, innerErr)
 			}
 
 			err = netlink.LinkSetUp(ipoibInterfaceLink)
@@ -117,42 +185,290 @@ func cmdAdd(args *skel.CmdArgs) error {
 	}
 
 	result.DNS = n.DNS
+	if err = saveAttachment(store, args, n, ibLink, result); err != nil {
+		return err
+	}
 
-	return cniTypes.PrintResult(result, cniVersion)
+	// The steps are complete, an interruption while printing the result must not roll them back
+	if err = jrnl.Finish(intent); err != nil {
+		return err
+	}
+	return printResult(result, ibLink, cniVersion)
 }
 
-func cmdDel(args *skel.CmdArgs) error {
-	n, _, err := config.LoadConf(args.StdinData)
+// initLogging sets up the logging from the log settings of the netconf loaded by LoadConf, and logs the
+// netconf fields it ignored
+func initLogging(n *types.NetConf) error {
+	if err := logging.Init(n.LogLevel, n.LogFile); err != nil {
+		return err
+	}
+	if len(n.UnknownFields) > 0 {
+		slog.Warn("ignoring unknown netconf fields", "fields", n.UnknownFields)
+	}
+	slog.Debug("loaded netconf", "netconf", logging.JSON(n))
+	return nil
+}
+
+// warnMembership warns about the effective membership of the child, which the port pkey table decides
+func warnMembership(args *skel.CmdArgs, n *types.NetConf, ibLink *types.IpoibLink) {
+	switch {
+	case ibLink.PkeyMembership == types.PkeyMembershipLimited:
+		slog.Warn("limited partition membership, a limited member cannot reach other limited members of the "+
+			"partition, including other pods attached to the network",
+			"ifName", args.IfName, "pkey", ibLink.Pkey, "network", n.Name)
+	case n.PkeyMembership == types.PkeyMembershipLimited:
+		slog.Warn("limited partition membership requested, but the port of the master is a full member of the "+
+			"partition, the kernel makes the child a full member",
+			"ifName", args.IfName, "pkey", ibLink.Pkey, "network", n.Name)
+	}
+}
+
+// reportReused prints the result of a retried ADD, which reuses the child of the previous ADD without running
+// IPAM or configuring the child again. The result of the previous ADD is reported as recorded, with the routes
+// and DNS settings of IPAM, otherwise it is rebuilt from the live addresses of the child.
+func reportReused(store *state.Store, args *skel.CmdArgs, n *types.NetConf, attachment *state.Attachment,
+	ibLink *types.IpoibLink, cniVersion string,
+) error {
+	if attachment != nil && attachment.Result != nil && attachment.IfIndex == ibLink.Index {
+		result := *attachment.Result
+		result.CNIVersion = cniVersion
+		return printResult(&result, ibLink, cniVersion)
+	}
+
+	if attachment == nil {
+		// DEL needs the state, which the previous ADD may not have recorded
+		if err := saveAttachment(store, args, n, ibLink, nil); err != nil {
+			return err
+		}
+	}
+	result := &current.Result{
+		CNIVersion: cniVersion,
+		Interfaces: []*current.Interface{ibLink.Interface},
+		IPs:        ibLink.IPs,
+		DNS:        n.DNS,
+	}
+	return printResult(result, ibLink, cniVersion)
+}
+
+// saveAttachment records what ADD created for the attachment, and the result of ADD once complete
+func saveAttachment(store *state.Store, args *skel.CmdArgs, n *types.NetConf, ibLink *types.IpoibLink,
+	result *current.Result,
+) error {
+	return store.Save(&state.Attachment{
+		ContainerID: args.ContainerID,
+		IfName:      args.IfName,
+		Network:     n.Name,
+		Netns:       args.Netns,
+		Master:      ibLink.Master,
+		Pkey:        ibLink.Pkey,
+		IfIndex:     ibLink.Index,
+		TempName:    ibLink.TempName,
+		IPAMType:    n.IPAM.Type,
+		Result:      result,
+	})
+}
+
+// recoverInterrupted rolls back the ADDs interrupted by a crash, whatever the verb of the invocation
+func recoverInterrupted() {
+	jrnl := journal.New(journalDir)
+	// Each intent is locked by this invocation, concurrent ones do not roll back the same ADD
+	intents, err := jrnl.Unfinished()
+	if err != nil {
+		return
+	}
+	for _, intent := range intents {
+		// A failed rollback is retried by the next invocation
+		if err = rollback(intent); err != nil {
+			slog.Warn("failed to roll back interrupted ADD", "containerID", intent.ContainerID,
+				"ifName", intent.IfName, "error", err)
+			jrnl.Release(intent)
+			continue
+		}
+		slog.Info("rolled back interrupted ADD", "containerID", intent.ContainerID, "ifName", intent.IfName)
+		_ = jrnl.Finish(intent)
+	}
+}
+
+// rollback undoes the steps started by an interrupted ADD. The attachment may have been added since by another
+// ADD, whose state records another child, its addresses and state are kept.
+func rollback(intent *journal.Intent) error {
+	store := state.New(stateDir)
+	attachment, err := store.Load(intent.ContainerID, intent.IfName)
+	if err != nil {
+		slog.Warn("ignoring unreadable attachment state", "error", err)
+	}
+	owned := attachment == nil || (intent.IfIndex != 0 && attachment.IfIndex == intent.IfIndex)
+
+	var errs []error
+	if intent.Started(journal.StepIPAM) && owned {
+		if err = releaseIPAM(intent); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if intent.Started(journal.StepLink) {
+		ipoibManager := newManager()
+		if err = ipoibManager.RemoveHostLink(intent.TempName); err != nil {
+			errs = append(errs, err)
+		}
+		if netns, nsErr := ns.GetNS(intent.Netns); nsErr == nil {
+			// ADD may have been interrupted between moving the child to the pod and renaming it
+			if err = ipoibManager.RemoveIpoibLink(intent.TempName, netns); err != nil {
+				errs = append(errs, err)
+			}
+			// Without a recorded index the child was never renamed, a link with the name belongs to another ADD
+			if intent.IfIndex != 0 {
+				if err = ipoibManager.RemoveIpoibLinkWithIndex(intent.IfName, intent.IfIndex, netns); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			_ = netns.Close()
+		}
+	}
+	if owned {
+		if err = store.Delete(intent.ContainerID, intent.IfName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// releaseIPAM runs DEL of the IPAM plugin with the arguments of the interrupted ADD
+func releaseIPAM(intent *journal.Intent) error {
+	pluginPath, err := invoke.FindInPath(intent.IPAMType, filepath.SplitList(intent.Path))
 	if err != nil {
 		return err
 	}
+	err = invoke.ExecPluginWithoutResult(context.TODO(), pluginPath, intent.Netconf, &invoke.Args{
+		Command:       "DEL",
+		ContainerID:   intent.ContainerID,
+		NetNS:         intent.Netns,
+		IfName:        intent.IfName,
+		PluginArgsStr: intent.Args,
+		Path:          intent.Path,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to release the addresses with IPAM plugin %s: %v", intent.IPAMType, err)
+	}
+	return nil
+}
 
-	isIpamProvided := n.IPAM.Type != ""
+// printResult prints the CNI result, with the settings the IPoIB link was created with in the "ipoib" field of
+// its interface entry, which other consumers of the result ignore
+func printResult(result *current.Result, ibLink *types.IpoibLink, cniVersion string) error {
+	versioned, err := result.GetAsVersion(cniVersion)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(versioned)
+	if err != nil {
+		return err
+	}
 
-	if isIpamProvided {
-		if n.IPAM.Type == dhcpType {
-			return fmt.Errorf("ipam dhcp type is not supported")
+	fields := map[string]json.RawMessage{}
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	// results before 0.3.0 have no interfaces
+	if raw, ok := fields["interfaces"]; ok {
+		var interfaces []map[string]json.RawMessage
+		if err = json.Unmarshal(raw, &interfaces); err != nil {
+			return err
 		}
-		err = ipam.ExecDel(n.IPAM.Type, args.StdinData)
-		if err != nil {
+		for _, iface := range interfaces {
+			var name string
+			if err = json.Unmarshal(iface["name"], &name); err != nil || name != ibLink.Interface.Name {
+				continue
+			}
+			if iface["ipoib"], err = json.Marshal(ibLink); err != nil {
+				return err
+			}
+		}
+		if fields["interfaces"], err = json.Marshal(interfaces); err != nil {
 			return err
 		}
 	}
 
-	netns, err := ns.GetNS(args.Netns)
+	data, err = json.MarshalIndent(fields, "", "    ")
 	if err != nil {
-		_, ok := err.(ns.NSPathNotExistErr)
-		if ok {
-			return nil
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func cmdDel(args *skel.CmdArgs) error {
+	// DEL attempts every step, so that a failed one does not leave the others undone while the runtime retries
+	steps := &cleanup{}
+	store := state.New(stateDir)
+	attachment, err := store.Load(args.ContainerID, args.IfName)
+	if err != nil {
+		// a retry would not read it either, it is removed below
+		slog.Warn("ignoring unreadable attachment state", "error", err)
+	}
+	// The state records what ADD created, the netconf is only needed without it. It is parsed leniently, a
+	// netconf or node file changed since ADD must not leak the attachment.
+	n, err := config.LoadConfLenient(args.StdinData)
+	if attachment == nil {
+		steps.record("netconf load", err)
+	}
+
+	ipamType, netnsPath := "", args.Netns
+	if attachment != nil {
+		ipamType = attachment.IPAMType
+		if netnsPath == "" {
+			netnsPath = attachment.Netns
 		}
+	} else if n != nil {
+		ipamType = n.IPAM.Type
+	}
 
-		return fmt.Errorf("failed to open netns %s: %q", netns, err)
+	// ADD rejects dhcp before running the IPAM plugin, there is nothing to release
+	if ipamType != "" && ipamType != dhcpType {
+		steps.record("IPAM release", ipam.ExecDel(ipamType, args.StdinData))
+	}
+
+	ipoibManager := newManager()
+	if attachment != nil {
+		// the pod network namespace may be gone, the child could be left in the host one
+		steps.record("host link deletion", ipoibManager.RemoveHostLink(attachment.TempName))
 	}
-	defer func() { _ = netns.Close() }()
 
-	ipoibManager := ipoib.NewIpoibManager()
+	// The addresses, routes and sysctls set on ADD go away with the link in the pod
+	netns, err := ns.GetNS(netnsPath)
+	if err != nil {
+		if _, ok := err.(ns.NSPathNotExistErr); !ok {
+			steps.record("link deletion", fmt.Errorf("failed to open netns %s: %q", netnsPath, err))
+		}
+	} else {
+		defer func() { _ = netns.Close() }()
+		steps.record("link deletion", ipoibManager.RemoveIpoibLink(args.IfName, netns))
+	}
 
-	return ipoibManager.RemoveIpoibLink(args.IfName, netns)
+	// A retried DEL needs the state to redo the failed steps, GC removes it if the runtime gives up
+	if len(steps.failed) == 0 {
+		steps.record("state removal", store.Delete(args.ContainerID, args.IfName))
+	}
+	return steps.err()
+}
+
+// cleanup collects the failed steps of DEL
+type cleanup struct {
+	failed []string
+}
+
+// record records the step as failed if err is not nil
+func (c *cleanup) record(step string, err error) {
+	if err != nil {
+		c.failed = append(c.failed, fmt.Sprintf("%s: %v", step, err))
+	}
+}
+
+// err returns a single error listing the failed steps, or nil if none failed
+func (c *cleanup) err() error {
+	if len(c.failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to clean up the attachment: %s", strings.Join(c.failed, "; "))
 }
 
 func main() {
@@ -169,10 +485,66 @@ func main() {
 		return
 	}
 
-	skel.PluginMainFuncs(skel.CNIFuncs{Add: cmdAdd, Check: cmdCheck, Del: cmdDel},
+	switch flag.Arg(0) {
+	case "":
+	case "schema":
+		if _, err := os.Stdout.Write(config.Schema); err != nil {
+			os.Exit(1)
+		}
+		return
+	case "validate":
+		os.Exit(runValidate(flag.Args()[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, supported commands: schema, validate\n", flag.Arg(0))
+		os.Exit(1)
+	}
+
+	recoverInterrupted()
+	skel.PluginMainFuncs(skel.CNIFuncs{Add: cmdAdd, Check: cmdCheck, Del: cmdDel, GC: cmdGC, Status: cmdStatus},
 		cniversion.All, bv.BuildString("ipoib-cni"))
 }
 
+// runValidate validates the network configurations in the files, or stdin if none or "-" is given, prints
+// the findings as JSON and returns the exit code, 1 if any error is found
+func runValidate(files []string) int {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	findings := []*validate.Finding{}
+	for _, file := range files {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file) //nolint:gosec // the file is given by the user
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", file, err)
+			return 1
+		}
+
+		fileFindings, err := validate.Validate(file, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		findings = append(findings, fileFindings...)
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"findings": findings}, "", "    ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print findings: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	if validate.HasErrors(findings) {
+		return 1
+	}
+	return 0
+}
+
 func printVersionString() string {
 	return fmt.Sprintf("ipoib-cni version:%s, commit:%s, date:%s", version, commit, date)
 }
@@ -182,6 +554,16 @@ func cmdCheck(args *skel.CmdArgs) error {
 	if err != nil {
 		return err
 	}
+	if err = initLogging(n); err != nil {
+		return err
+	}
+	if err = config.ApplyOverrides(n, args.Args); err != nil {
+		return err
+	}
+	attachment, err := state.New(stateDir).Load(args.ContainerID, args.IfName)
+	if err != nil {
+		return err
+	}
 	isIpamProvided := n.IPAM.Type != ""
 
 	netns, err := ns.GetNS(args.Netns)
@@ -238,6 +620,20 @@ func cmdCheck(args *skel.CmdArgs) error {
 			return err
 		}
 
+		link, err := netlink.LinkByName(args.IfName)
+		if err != nil {
+			return fmt.Errorf("failed to find interface name %q: %v", args.IfName, err)
+		}
+		err = ipoib.ValidateIpoibLink(link, n, attachment)
+		if err != nil {
+			return err
+		}
+		if attachment != nil {
+			if err = ipoib.ValidateAttachment(link, attachment); err != nil {
+				return err
+			}
+		}
+
 		err = ip.ValidateExpectedInterfaceIPs(args.IfName, result.IPs)
 		if err != nil {
 			return err
@@ -255,6 +651,53 @@ func cmdCheck(args *skel.CmdArgs) error {
 	return nil
 }
 
+// cmdStatus reports the plugin as not available when the master cannot serve ADD requests or the IPAM
+// plugin is not available
+func cmdStatus(args *skel.CmdArgs) error {
+	n, _, err := config.LoadConf(args.StdinData)
+	if err != nil {
+		return err
+	}
+	if err = initLogging(n); err != nil {
+		return err
+	}
+
+	ipoibManager := newManager()
+	if err = ipoibManager.CheckMaster(n); err != nil {
+		return cniTypes.NewError(types.ErrPluginNotAvailable, "master is not available", err.Error())
+	}
+
+	if n.IPAM.Type != "" {
+		if err = ipam.ExecStatus(n.IPAM.Type, args.StdinData); err != nil {
+			return cniTypes.NewError(types.ErrPluginNotAvailable, "IPAM plugin is not available",
+				fmt.Sprintf("%s: %v", n.IPAM.Type, err))
+		}
+	}
+	return nil
+}
+
+// cmdGC deletes the IPoIB children and the IPAM allocations of the network which do not belong to the
+// valid attachments, and the children leaked in the host network namespace. Like DEL, it parses the netconf
+// leniently.
+func cmdGC(args *skel.CmdArgs) error {
+	n, err := config.LoadConfLenient(args.StdinData)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	ipoibManager := newManager()
+	if err = ipoibManager.GarbageCollect(n, n.ValidAttachments); err != nil {
+		errs = append(errs, err)
+	}
+	if n.IPAM.Type != "" {
+		if err = invoke.DelegateGC(context.TODO(), n.IPAM.Type, args.StdinData, nil); err != nil {
+			errs = append(errs, fmt.Errorf("failed to GC IPAM plugin %s: %v", n.IPAM.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func validateCniContainerInterface(iface *current.Interface) error {
 	var link netlink.Link
 	var err error
@@ -278,7 +721,13 @@ func validateCniContainerInterface(iface *current.Interface) error {
 	return nil
 }
 
-func handleIpamConfig(netConfig *types.NetConf, args *skel.CmdArgs, netns ns.NetNS, result *current.Result) error {
+func handleIpamConfig(netConfig *types.NetConf, args *skel.CmdArgs, netns ns.NetNS, result *current.Result,
+	intent *journal.Intent,
+) error {
+	if err := intent.Record(journal.StepIPAM); err != nil {
+		return err
+	}
+
 	// run the IPAM plugin and get back the config to apply
 	r, err := ipam.ExecAdd(netConfig.IPAM.Type, args.StdinData)
 	if err != nil {
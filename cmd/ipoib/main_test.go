@@ -0,0 +1,272 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	current "github.com/containernetworking/cni/pkg/types/100"
+	"github.com/stretchr/testify/mock"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/config"
+	"github.com/Mellanox/ipoib-cni/pkg/journal"
+	"github.com/Mellanox/ipoib-cni/pkg/state"
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+	"github.com/Mellanox/ipoib-cni/pkg/types/mocks"
+)
+
+var _ = Describe("Ipoib Cmd", func() {
+	var manager *mocks.Manager
+
+	BeforeEach(func() {
+		manager = &mocks.Manager{}
+		origManager, origStateDir, origJournalDir := newManager, stateDir, journalDir
+		newManager = func() types.Manager { return manager }
+		stateDir, journalDir = GinkgoT().TempDir(), GinkgoT().TempDir()
+		DeferCleanup(func() { newManager, stateDir, journalDir = origManager, origStateDir, origJournalDir })
+		// the node files of the host running the tests are not read
+		DeferCleanup(config.SetNodeDir(GinkgoT().TempDir()))
+	})
+
+	Context("Checking cmdAdd function", func() {
+		var args *skel.CmdArgs
+
+		BeforeEach(func() {
+			args = &skel.CmdArgs{
+				ContainerID: "container",
+				IfName:      "net1",
+				// the netns of the test stands for the netns of the pod, the manager is mocked
+				Netns: "/proc/self/ns/net",
+				StdinData: []byte(`{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0",
+					"ipam": {"type": "host-local", "subnet": "10.0.0.0/24"}}`),
+			}
+		})
+
+		// captureStdout returns what run prints on the standard output
+		captureStdout := func(run func() error) (string, error) {
+			r, w, err := os.Pipe()
+			Expect(err).NotTo(HaveOccurred())
+			stdout := os.Stdout
+			os.Stdout = w
+			runErr := run()
+			os.Stdout = stdout
+			Expect(w.Close()).To(Succeed())
+			out, err := io.ReadAll(r)
+			Expect(err).NotTo(HaveOccurred())
+			return string(out), runErr
+		}
+
+		It("Assuming retried ADD with the child of the previous ADD in the pod", func() {
+			ipNet := net.IPNet{IP: net.ParseIP("10.0.0.5").To4(), Mask: net.CIDRMask(24, 32)}
+			reused := &types.IpoibLink{
+				Interface: &current.Interface{Name: "net1", Mac: "00:00:01:00:fe:80", Sandbox: args.Netns},
+				Pkey:      0xffff,
+				Master:    "ib0",
+				Index:     7,
+				IPs:       []*current.IPConfig{{Interface: current.Int(0), Address: ipNet}},
+			}
+			manager.On("ReuseIpoibLink", mock.Anything, "net1", mock.Anything, "").Return(reused, nil)
+
+			out, err := captureStdout(func() error { return cmdAdd(args) })
+			Expect(err).NotTo(HaveOccurred())
+
+			result := &current.Result{}
+			Expect(json.Unmarshal([]byte(out), result)).To(Succeed())
+			Expect(result.Interfaces).To(Equal([]*current.Interface{reused.Interface}))
+			Expect(result.IPs).To(HaveLen(1))
+			Expect(result.IPs[0].Address.String()).To(Equal("10.0.0.5/24"))
+			manager.AssertNotCalled(GinkgoT(), "CreateIpoibLink", mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything, mock.Anything)
+
+			attachment, err := state.New(stateDir).Load("container", "net1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attachment.Master).To(Equal("ib0"))
+			Expect(attachment.IfIndex).To(Equal(7))
+			Expect(os.ReadDir(journalDir)).To(BeEmpty())
+		})
+		It("Assuming retried ADD with the master recorded by the previous ADD", func() {
+			Expect(state.New(stateDir).Save(&state.Attachment{ContainerID: "container", IfName: "net1",
+				Network: "mynet", Master: "ib1", IfIndex: 7})).To(Succeed())
+			reused := &types.IpoibLink{Interface: &current.Interface{Name: "net1"}, Master: "ib1", Index: 7}
+			manager.On("ReuseIpoibLink", mock.Anything, "net1", mock.Anything, "ib1").Return(reused, nil)
+
+			_, err := captureStdout(func() error { return cmdAdd(args) })
+			Expect(err).NotTo(HaveOccurred())
+			manager.AssertExpectations(GinkgoT())
+		})
+		It("Assuming retried ADD reports the result recorded by the previous ADD", func() {
+			_, gateway, _ := net.ParseCIDR("0.0.0.0/0")
+			recorded := &current.Result{
+				CNIVersion: "1.0.0",
+				Interfaces: []*current.Interface{{Name: "net1", Sandbox: args.Netns}},
+				IPs: []*current.IPConfig{{Interface: current.Int(0), Gateway: net.ParseIP("10.0.0.1").To4(),
+					Address: net.IPNet{IP: net.ParseIP("10.0.0.5").To4(), Mask: net.CIDRMask(24, 32)}}},
+				Routes: []*cniTypes.Route{{Dst: *gateway, GW: net.ParseIP("10.0.0.1").To4()}},
+				DNS:    cniTypes.DNS{Nameservers: []string{"10.0.0.53"}},
+			}
+			Expect(state.New(stateDir).Save(&state.Attachment{ContainerID: "container", IfName: "net1",
+				Network: "mynet", Master: "ib0", IfIndex: 7, Result: recorded})).To(Succeed())
+			reused := &types.IpoibLink{Interface: &current.Interface{Name: "net1", Sandbox: args.Netns},
+				Master: "ib0", Index: 7}
+			manager.On("ReuseIpoibLink", mock.Anything, "net1", mock.Anything, "ib0").Return(reused, nil)
+
+			out, err := captureStdout(func() error { return cmdAdd(args) })
+			Expect(err).NotTo(HaveOccurred())
+
+			result := &current.Result{}
+			Expect(json.Unmarshal([]byte(out), result)).To(Succeed())
+			Expect(result.IPs).To(HaveLen(1))
+			Expect(result.IPs[0].Gateway.String()).To(Equal("10.0.0.1"))
+			Expect(result.Routes).To(HaveLen(1))
+			Expect(result.Routes[0].GW.String()).To(Equal("10.0.0.1"))
+			Expect(result.DNS.Nameservers).To(Equal([]string{"10.0.0.53"}))
+		})
+		It("Assuming retried ADD rebuilds the result when the recorded one is of another child", func() {
+			_, gateway, _ := net.ParseCIDR("0.0.0.0/0")
+			recorded := &current.Result{CNIVersion: "1.0.0",
+				Routes: []*cniTypes.Route{{Dst: *gateway, GW: net.ParseIP("10.0.0.1").To4()}}}
+			Expect(state.New(stateDir).Save(&state.Attachment{ContainerID: "container", IfName: "net1",
+				Network: "mynet", Master: "ib0", IfIndex: 5, Result: recorded})).To(Succeed())
+			reused := &types.IpoibLink{Interface: &current.Interface{Name: "net1", Sandbox: args.Netns},
+				Master: "ib0", Index: 7}
+			manager.On("ReuseIpoibLink", mock.Anything, "net1", mock.Anything, "ib0").Return(reused, nil)
+
+			out, err := captureStdout(func() error { return cmdAdd(args) })
+			Expect(err).NotTo(HaveOccurred())
+
+			result := &current.Result{}
+			Expect(json.Unmarshal([]byte(out), result)).To(Succeed())
+			Expect(result.Routes).To(BeEmpty())
+		})
+		It("Assuming result with the IPoIB settings in the interface entry", func() {
+			reused := &types.IpoibLink{
+				Interface:      &current.Interface{Name: "net1", Mtu: 2044, Sandbox: args.Netns},
+				Pkey:           0x8001,
+				PkeyMembership: types.PkeyMembershipFull,
+				Mode:           types.IpoibModeDatagram,
+				MTU:            2044,
+				Master:         "ib0",
+				Index:          7,
+			}
+			manager.On("ReuseIpoibLink", mock.Anything, "net1", mock.Anything, "").Return(reused, nil)
+
+			out, err := captureStdout(func() error { return cmdAdd(args) })
+			Expect(err).NotTo(HaveOccurred())
+
+			var result struct {
+				Interfaces []struct {
+					Name  string          `json:"name"`
+					Mtu   int             `json:"mtu"`
+					Ipoib json.RawMessage `json:"ipoib"`
+				} `json:"interfaces"`
+				Ipoib json.RawMessage `json:"ipoib"`
+			}
+			Expect(json.Unmarshal([]byte(out), &result)).To(Succeed())
+			Expect(result.Ipoib).To(BeNil())
+			Expect(result.Interfaces).To(HaveLen(1))
+			Expect(result.Interfaces[0].Mtu).To(Equal(2044))
+			Expect(result.Interfaces[0].Ipoib).To(MatchJSON(`{"pkey": "0x8001", "pkeyMembership": "full",
+				"mode": "datagram", "umcast": false, "mtu": 2044}`))
+		})
+		It("Assuming another interface with the name in the pod", func() {
+			manager.On("ReuseIpoibLink", mock.Anything, "net1", mock.Anything, "").Return(nil,
+				cniTypes.NewError(types.ErrAlreadyExists, "interface already exists", "not an IPoIB interface"))
+
+			err := cmdAdd(args)
+			Expect(err).To(HaveOccurred())
+			cniErr, ok := err.(*cniTypes.Error)
+			Expect(ok).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrAlreadyExists))
+			manager.AssertNotCalled(GinkgoT(), "CreateIpoibLink", mock.Anything, mock.Anything, mock.Anything,
+				mock.Anything, mock.Anything)
+			Expect(os.ReadDir(journalDir)).To(BeEmpty())
+		})
+	})
+	Context("Checking recoverInterrupted function", func() {
+		const (
+			tempName = "veth0123abcd"
+			// the netns of the test stands for the netns of the pod, the manager is mocked
+			netnsPath = "/proc/self/ns/net"
+		)
+
+		// interrupt journals the steps of an ADD and leaves its intent as a killed ADD would
+		interrupt := func(index int, steps ...journal.Step) {
+			jrnl := journal.New(journalDir)
+			intent := &journal.Intent{ContainerID: "container", IfName: "net1", Netns: netnsPath,
+				Netconf: []byte(`{}`), IPAMType: "host-local"}
+			Expect(jrnl.Begin(intent)).To(Succeed())
+			Expect(intent.RecordLink(tempName)).To(Succeed())
+			if index != 0 {
+				Expect(intent.RecordIndex(index)).To(Succeed())
+			}
+			for _, step := range steps {
+				Expect(intent.Record(step)).To(Succeed())
+			}
+			jrnl.Release(intent)
+		}
+
+		It("Assuming ADD killed after moving the child to the pod and before renaming it", func() {
+			interrupt(0)
+			manager.On("RemoveHostLink", tempName).Return(nil)
+			manager.On("RemoveIpoibLink", tempName, mock.Anything).Return(nil)
+
+			recoverInterrupted()
+
+			manager.AssertExpectations(GinkgoT())
+			manager.AssertNotCalled(GinkgoT(), "RemoveIpoibLinkWithIndex", mock.Anything, mock.Anything,
+				mock.Anything)
+			Expect(os.ReadDir(journalDir)).To(BeEmpty())
+		})
+		It("Assuming ADD killed after renaming the child", func() {
+			interrupt(9)
+			manager.On("RemoveHostLink", tempName).Return(nil)
+			manager.On("RemoveIpoibLink", tempName, mock.Anything).Return(nil)
+			manager.On("RemoveIpoibLinkWithIndex", "net1", 9, mock.Anything).Return(nil)
+
+			recoverInterrupted()
+
+			manager.AssertExpectations(GinkgoT())
+			Expect(os.ReadDir(journalDir)).To(BeEmpty())
+		})
+		It("Assuming ADD killed after IPAM and the attachment added since by another ADD", func() {
+			interrupt(9, journal.StepIPAM)
+			store := state.New(stateDir)
+			Expect(store.Save(&state.Attachment{ContainerID: "container", IfName: "net1", Network: "mynet",
+				IfIndex: 12, IPAMType: "host-local"})).To(Succeed())
+			manager.On("RemoveHostLink", tempName).Return(nil)
+			manager.On("RemoveIpoibLink", tempName, mock.Anything).Return(nil)
+			manager.On("RemoveIpoibLinkWithIndex", "net1", 9, mock.Anything).Return(nil)
+
+			// the IPAM plugin is not in CNI_PATH, releasing the addresses would fail the rollback
+			recoverInterrupted()
+
+			manager.AssertExpectations(GinkgoT())
+			Expect(os.ReadDir(journalDir)).To(BeEmpty())
+			attachment, err := store.Load("container", "net1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(attachment.IfIndex).To(Equal(12))
+		})
+	})
+})
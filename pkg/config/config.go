@@ -18,19 +18,216 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
 
+	"github.com/Mellanox/ipoib-cni/pkg/logging"
 	"github.com/Mellanox/ipoib-cni/pkg/types"
 )
 
-// LoadConf parses and validates stdin netconf and returns NetConf object
+// DefaultsFile holds node level defaults of netconf settings
+const DefaultsFile = "/etc/cni/ipoib.d/defaults.json"
+
+var (
+	// defaultsFile is the node defaults file read by LoadConf
+	defaultsFile = DefaultsFile
+
+	pciAddrRegexp = regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-7]$`)
+	guidRegexp    = regexp.MustCompile(`^(0[xX])?[0-9a-fA-F]{16}$`)
+)
+
+// SetNodeDir makes LoadConf and LoadPolicy read the node files from dir instead of /etc/cni/ipoib.d, and
+// returns a function restoring the node files. It lets the tests of the callers run without the node files.
+func SetNodeDir(dir string) (restore func()) {
+	origDefaults, origPolicy, origPartitions := defaultsFile, policyFile, partitionsFile
+	defaultsFile = filepath.Join(dir, filepath.Base(DefaultsFile))
+	policyFile = filepath.Join(dir, filepath.Base(PolicyFile))
+	partitionsFile = filepath.Join(dir, filepath.Base(PartitionsFile))
+	return func() { defaultsFile, policyFile, partitionsFile = origDefaults, origPolicy, origPartitions }
+}
+
+// nodeDefaults holds the netconf settings which can be set per node
+type nodeDefaults struct {
+	MTU            int    `json:"mtu,omitempty"`
+	MTUPolicy      string `json:"mtuPolicy,omitempty"`
+	Mode           string `json:"mode,omitempty"`
+	Umcast         *bool  `json:"umcast,omitempty"`
+	PkeyMembership string `json:"pkeyMembership,omitempty"`
+	MaxChildren    int    `json:"maxChildren,omitempty"`
+	LogLevel       string `json:"logLevel,omitempty"`
+	LogFile        string `json:"logFile,omitempty"`
+}
+
+// LoadConf parses and validates stdin netconf and returns NetConf object. The caller sets up the logging from
+// its log settings.
 func LoadConf(bytes []byte) (*types.NetConf, string, error) {
+	n, err := parseConf(bytes, false)
+	if err != nil {
+		return nil, "", err
+	}
+	if n.Partition != "" {
+		if err = applyPartition(n, partitionsFile); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = applyNodeDefaults(n); err != nil {
+		return nil, "", err
+	}
+
+	// the node files may set invalid values as well
+	var errs fieldErrors
+	errs.validateIpoibSettings(n, "")
+	errs.validateMTUPolicy(n.MTUPolicy)
+	if n.MaxChildren < 0 {
+		errs.add("maxChildren", "must not be negative, got %d", n.MaxChildren)
+	}
+	errs.validateLogLevel(n.LogLevel)
+	if err = errs.err(); err != nil {
+		return nil, "", err
+	}
+	return n, n.CNIVersion, nil
+}
+
+// LoadConfLenient parses the netconf of DEL and GC, which must clean up what ADD created even if the netconf
+// or the node files changed since. Unknown and invalid fields are ignored, and only the log settings are
+// read from the node defaults file, if it is readable.
+func LoadConfLenient(bytes []byte) (*types.NetConf, error) {
 	n := &types.NetConf{}
 	if err := json.Unmarshal(bytes, n); err != nil {
-		return nil, "", fmt.Errorf("failed to load netconf: %v", err)
+		// the fields of the wrong type are left unset, the others are still decoded
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("failed to load netconf: %v", err)
+		}
 	}
-	if n.Master == "" {
-		return nil, "", fmt.Errorf("host master interface is missing")
+	var errs fieldErrors
+	errs.mergeDeviceID(n)
+	errs.mergeIpoibAttrs(n)
+
+	defaults, defaultsErr := readNodeDefaults(defaultsFile, true)
+	if defaultsErr == nil {
+		if n.LogLevel == "" {
+			n.LogLevel = defaults.LogLevel
+		}
+		n.LogFile = defaults.LogFile
 	}
-	return n, n.CNIVersion, nil
+	if err := logging.Init(n.LogLevel, n.LogFile); err != nil {
+		_ = logging.Init("", "")
+		slog.Warn("ignoring invalid log settings", "error", err)
+	}
+	if defaultsErr != nil {
+		slog.Warn("ignoring unreadable node defaults", "error", defaultsErr)
+	}
+	if err := errs.err(); err != nil {
+		slog.Warn("ignoring invalid netconf fields", "error", err)
+	}
+	slog.Debug("loaded netconf", "netconf", logging.JSON(n))
+	return n, nil
+}
+
+// validatePkey checks that pkey fits in 16 bits and is not one of the reserved values
+func validatePkey(pkey types.Pkey) error {
+	if pkey <= 0 || pkey > types.MaxPkey {
+		return fmt.Errorf("must be in range 0x0001-0xffff, got %d", int(pkey))
+	}
+	if pkey&types.PkeyMask == 0 {
+		return fmt.Errorf("%s is reserved", pkey)
+	}
+	return nil
+}
+
+// mergeIpoibAttrs copies the attributes set in the ipoibAttrs block to the corresponding netconf
+// fields. An attribute may be set in both places only if the values are equal.
+func (errs *fieldErrors) mergeIpoibAttrs(n *types.NetConf) {
+	attrs := n.IpoibAttrs
+	if attrs == nil {
+		return
+	}
+
+	if attrs.Pkey != nil {
+		if n.Pkey != nil && *n.Pkey != *attrs.Pkey {
+			errs.add("ipoibAttrs.pkey", "%s conflicts with pkey %s", *attrs.Pkey, *n.Pkey)
+		}
+		n.Pkey = attrs.Pkey
+	}
+	if attrs.Mode != "" {
+		if n.Mode != "" && n.Mode != attrs.Mode {
+			errs.add("ipoibAttrs.mode", "%q conflicts with mode %q", attrs.Mode, n.Mode)
+		}
+		n.Mode = attrs.Mode
+	}
+	if attrs.Umcast != nil {
+		if n.Umcast != nil && *n.Umcast != *attrs.Umcast {
+			errs.add("ipoibAttrs.umcast", "%t conflicts with umcast %t", *attrs.Umcast, *n.Umcast)
+		}
+		n.Umcast = attrs.Umcast
+	}
+}
+
+// mergeDeviceID sets the deviceID passed as a runtime capability argument as the netconf deviceID
+func (errs *fieldErrors) mergeDeviceID(n *types.NetConf) {
+	if n.RuntimeConfig == nil || n.RuntimeConfig.DeviceID == "" {
+		return
+	}
+	if n.DeviceID != "" && n.DeviceID != n.RuntimeConfig.DeviceID {
+		errs.add("runtimeConfig.deviceID", "%q conflicts with deviceID %q", n.RuntimeConfig.DeviceID, n.DeviceID)
+	}
+	n.DeviceID = n.RuntimeConfig.DeviceID
+}
+
+// applyNodeDefaults sets the settings missing in the netconf from the node defaults file. The default
+// node defaults file is optional, a file set in the netconf must exist.
+func applyNodeDefaults(n *types.NetConf) error {
+	path := defaultsFile
+	if n.DefaultsFile != "" {
+		path = n.DefaultsFile
+	}
+	defaults, err := readNodeDefaults(path, n.DefaultsFile == "")
+	if err != nil {
+		return err
+	}
+	if n.MTU == 0 {
+		n.MTU = defaults.MTU
+	}
+	if n.MTUPolicy == "" {
+		n.MTUPolicy = defaults.MTUPolicy
+	}
+	if n.Mode == "" {
+		n.Mode = defaults.Mode
+	}
+	if n.Umcast == nil {
+		n.Umcast = defaults.Umcast
+	}
+	if n.PkeyMembership == "" {
+		n.PkeyMembership = defaults.PkeyMembership
+	}
+	if n.MaxChildren == 0 {
+		n.MaxChildren = defaults.MaxChildren
+	}
+	if n.LogLevel == "" {
+		n.LogLevel = defaults.LogLevel
+	}
+	n.LogFile = defaults.LogFile
+	return nil
+}
+
+// readNodeDefaults reads the node defaults file, an optional file may be missing
+func readNodeDefaults(path string, optional bool) (*nodeDefaults, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // the path is in the directory of the node defaults file
+	if err != nil {
+		if os.IsNotExist(err) && optional {
+			return &nodeDefaults{}, nil
+		}
+		return nil, fmt.Errorf("failed to read node defaults: %v", err)
+	}
+
+	defaults := &nodeDefaults{}
+	if err = json.Unmarshal(data, defaults); err != nil {
+		return nil, fmt.Errorf("failed to load node defaults %q: %v", path, err)
+	}
+	return defaults, nil
 }
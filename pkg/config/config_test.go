@@ -17,8 +17,13 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
 )
 
 var _ = Describe("Config", func() {
@@ -75,5 +80,300 @@ var _ = Describe("Config", func() {
 			_, _, err := LoadConf(conf)
 			Expect(err).To(HaveOccurred())
 		})
+		It("Assuming config without pkey", func() {
+			conf := []byte(`{
+        "name": "mynet",
+        "type": "ipoib",
+        "master": "ib0"
+                        }`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Pkey).To(BeNil())
+		})
+		DescribeTable("Assuming valid pkey",
+			func(pkey string, expected int) {
+				conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkey": ` + pkey + `}`)
+				n, _, err := LoadConf(conf)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.Pkey).NotTo(BeNil())
+				Expect(*n.Pkey).To(Equal(types.Pkey(expected)))
+			},
+			Entry("hex string", `"0x8001"`, 0x8001),
+			Entry("upper case hex string", `"0X7FFF"`, 0x7fff),
+			Entry("decimal string", `"32769"`, 0x8001),
+			Entry("number", `10`, 10),
+		)
+		DescribeTable("Assuming invalid pkey",
+			func(pkey string) {
+				conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkey": ` + pkey + `}`)
+				_, _, err := LoadConf(conf)
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("zero", `0`),
+			Entry("reserved full member zero", `"0x8000"`),
+			Entry("negative", `-1`),
+			Entry("too large", `"0x10000"`),
+			Entry("not a number", `"storage"`),
+			Entry("boolean", `true`),
+		)
+		It("Assuming valid pkey membership", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkeyMembership": "full"}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.PkeyMembership).To(Equal(types.PkeyMembershipFull))
+		})
+		It("Assuming invalid pkey membership", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkeyMembership": "both"}`)
+			_, _, err := LoadConf(conf)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming valid mode", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "mode": "connected"}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Mode).To(Equal(types.IpoibModeConnected))
+		})
+		It("Assuming invalid mode", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "mode": "ud"}`)
+			_, _, err := LoadConf(conf)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming ipoibAttrs block", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+				"ipoibAttrs": {"pkey": "0x8001", "mode": "datagram", "umcast": false}}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8001)))
+			Expect(n.Mode).To(Equal(types.IpoibModeDatagram))
+			Expect(*n.Umcast).To(BeFalse())
+		})
+		It("Assuming ipoibAttrs block matching top level fields", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkey": 32769, "umcast": true,
+				"ipoibAttrs": {"pkey": "0x8001", "umcast": true}}`)
+			_, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+		})
+		DescribeTable("Assuming ipoibAttrs block conflicting with top level fields",
+			func(conf string) {
+				_, _, err := LoadConf([]byte(conf))
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("pkey", `{"name": "n", "master": "ib0", "pkey": "0x8001", "ipoibAttrs": {"pkey": "0x8002"}}`),
+			Entry("mode", `{"name": "n", "master": "ib0", "mode": "connected", "ipoibAttrs": {"mode": "datagram"}}`),
+			Entry("umcast", `{"name": "n", "master": "ib0", "umcast": true, "ipoibAttrs": {"umcast": false}}`),
+			Entry("invalid mode", `{"name": "n", "master": "ib0", "ipoibAttrs": {"mode": "ud"}}`),
+		)
+		DescribeTable("Assuming valid master selector",
+			func(selector string) {
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", ` + selector + `}`))
+				Expect(err).NotTo(HaveOccurred())
+			},
+			Entry("PCI address", `"masterPci": "0000:03:00.0"`),
+			Entry("PCI address and port", `"masterPci": "0000:03:00.0", "masterPort": 2`),
+			Entry("RDMA device", `"masterRdmaDevice": "mlx5_2"`),
+			Entry("RDMA device and port", `"masterRdmaDevice": "mlx5_2", "masterPort": 1`),
+			Entry("port GUID", `"masterPortGuid": "0x0c42a10300175a3c"`),
+			Entry("colon separated port GUID", `"masterPortGuid": "0c42:a103:0017:5a3c"`),
+		)
+		DescribeTable("Assuming invalid master selector",
+			func(selector string) {
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", ` + selector + `}`))
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("master and PCI address", `"master": "ib0", "masterPci": "0000:03:00.0"`),
+			Entry("RDMA device and port GUID", `"masterRdmaDevice": "mlx5_2", "masterPortGuid": "0x0c42a10300175a3c"`),
+			Entry("port without device", `"master": "ib0", "masterPort": 1`),
+			Entry("negative port", `"masterRdmaDevice": "mlx5_2", "masterPort": -1`),
+			Entry("malformed PCI address", `"masterPci": "03:00"`),
+			Entry("malformed port GUID", `"masterPortGuid": "0x0c42a103"`),
+		)
+		It("Assuming deviceID without master", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "deviceID": "0000:03:00.1"}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.DeviceID).To(Equal("0000:03:00.1"))
+		})
+		It("Assuming runtimeConfig deviceID", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib1",
+				"runtimeConfig": {"deviceID": "0000:03:00.1"}}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.DeviceID).To(Equal("0000:03:00.1"))
+		})
+		It("Assuming runtimeConfig deviceID conflicting with deviceID", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "deviceID": "0000:03:00.0",
+				"runtimeConfig": {"deviceID": "0000:03:00.1"}}`)
+			_, _, err := LoadConf(conf)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming malformed deviceID", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "deviceID": "mlx5_0"}`)
+			_, _, err := LoadConf(conf)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming masters pool", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "masters": ["ib0", "ib1"], "masterSelection": "leastLoaded"}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Masters).To(Equal([]string{"ib0", "ib1"}))
+		})
+		DescribeTable("Assuming invalid masters pool",
+			func(selector string) {
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", ` + selector + `}`))
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("masters and master", `"master": "ib0", "masters": ["ib1"]`),
+			Entry("empty master name", `"masters": ["ib0", ""]`),
+			Entry("duplicated master", `"masters": ["ib0", "ib0"]`),
+			Entry("selection without masters", `"master": "ib0", "masterSelection": "failover"`),
+			Entry("invalid selection", `"masters": ["ib0"], "masterSelection": "random"`),
+		)
+		Context("Assuming node defaults file", func() {
+			BeforeEach(func() {
+				path := filepath.Join(GinkgoT().TempDir(), "defaults.json")
+				Expect(os.WriteFile(path, []byte(`{"maxChildren": 64, "mtu": 4092, "mode": "connected",
+					"umcast": false, "logLevel": "info"}`), 0o600)).To(Succeed())
+				defaultsFile = path
+				DeferCleanup(func() { defaultsFile = DefaultsFile })
+			})
+
+			It("applies the node maxChildren", func() {
+				n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0"}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.MaxChildren).To(Equal(64))
+			})
+			It("prefers the netconf maxChildren", func() {
+				n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "maxChildren": 8}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.MaxChildren).To(Equal(8))
+			})
+			It("applies the node settings missing in the netconf", func() {
+				n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "mtu": 2044}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.MTU).To(Equal(2044))
+				Expect(n.Mode).To(Equal("connected"))
+				Expect(*n.Umcast).To(BeFalse())
+				Expect(n.LogLevel).To(Equal("info"))
+			})
+			It("prefers the netconf ipoibAttrs", func() {
+				n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"ipoibAttrs": {"mode": "datagram", "umcast": true}}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.Mode).To(Equal("datagram"))
+				Expect(*n.Umcast).To(BeTrue())
+			})
+			It("reads the defaults file set in the netconf", func() {
+				path := filepath.Join(filepath.Dir(defaultsFile), "gen2.json")
+				Expect(os.WriteFile(path, []byte(`{"mtu": 2044}`), 0o600)).To(Succeed())
+				n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"defaultsFile": "` + path + `"}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.MTU).To(Equal(2044))
+				Expect(n.Mode).To(BeEmpty())
+			})
+			It("fails when the defaults file set in the netconf is missing", func() {
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"defaultsFile": "` + filepath.Join(filepath.Dir(defaultsFile), "missing.json") + `"}`))
+				Expect(err).To(HaveOccurred())
+			})
+			It("rejects a defaults file outside of the node defaults directory", func() {
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"defaultsFile": "` + filepath.Join(filepath.Dir(defaultsFile), "..", "defaults.json") + `"}`))
+				Expect(err).To(MatchError(ContainSubstring("defaultsFile: must be a file in")))
+			})
+			DescribeTable("rejects a defaults file which is not a node defaults file",
+				func(name, message string) {
+					_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+						"defaultsFile": "` + filepath.Join(filepath.Dir(defaultsFile), name) + `"}`))
+					Expect(err).To(MatchError(ContainSubstring(message)))
+				},
+				Entry("not a JSON file", "passwd", "defaultsFile: must be a .json file"),
+				Entry("partitions file", "partitions.conf", "defaultsFile: must be a .json file"),
+				Entry("policy file", "policy.json", "defaultsFile: must not be the node file"),
+			)
+			It("rejects a symlink to a file outside of the node defaults directory", func() {
+				outside := filepath.Join(GinkgoT().TempDir(), "defaults.json")
+				Expect(os.WriteFile(outside, []byte(`{"mtu": 2044}`), 0o600)).To(Succeed())
+				link := filepath.Join(filepath.Dir(defaultsFile), "tenant.json")
+				Expect(os.Symlink(outside, link)).To(Succeed())
+
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"defaultsFile": "` + link + `"}`))
+				Expect(err).To(MatchError(ContainSubstring("defaultsFile: must be a file in")))
+			})
+			It("rejects a symlink to the policy file", func() {
+				dir := filepath.Dir(defaultsFile)
+				Expect(os.WriteFile(filepath.Join(dir, "policy.json"), []byte(`{}`), 0o600)).To(Succeed())
+				link := filepath.Join(dir, "tenant.json")
+				Expect(os.Symlink(filepath.Join(dir, "policy.json"), link)).To(Succeed())
+
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"defaultsFile": "` + link + `"}`))
+				Expect(err).To(MatchError(ContainSubstring("defaultsFile: must not be the node file")))
+			})
+			It("takes the log file from the node defaults only", func() {
+				n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+					"logFile": "/etc/passwd"}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(n.LogFile).To(BeEmpty())
+				Expect(n.UnknownFields).To(Equal([]string{"logFile"}))
+			})
+		})
+		It("Assuming invalid logLevel", func() {
+			_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "logLevel": "verbose"}`))
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming negative maxChildren", func() {
+			_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "maxChildren": -1}`))
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming autoCreateParent with pkey", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkey": "0x8001", "autoCreateParent": true}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.AutoCreateParent).To(BeTrue())
+		})
+		It("Assuming autoCreateParent without pkey", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "autoCreateParent": true}`)
+			_, _, err := LoadConf(conf)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking LoadConfLenient function", func() {
+		It("ignores unknown and invalid fields", func() {
+			n, err := LoadConfLenient([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "vf": 0,
+				"mtu": "large", "logLevel": "verbose", "ipam": {"type": "host-local"}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Name).To(Equal("mynet"))
+			Expect(n.Master).To(Equal("ib0"))
+			Expect(n.IPAM.Type).To(Equal("host-local"))
+		})
+		It("does not read the partitions and the defaults file set in the netconf", func() {
+			n, err := LoadConfLenient([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+				"partition": "missing", "defaultsFile": "/etc/cni/ipoib.d/missing.json"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.Pkey).To(BeNil())
+		})
+		It("ignores an unreadable node defaults file", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "defaults.json")
+			Expect(os.WriteFile(path, []byte(`{"mtu": "large"}`), 0o600)).To(Succeed())
+			defaultsFile = path
+			DeferCleanup(func() { defaultsFile = DefaultsFile })
+
+			_, err := LoadConfLenient([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0"}`))
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("merges the ipoibAttrs and the runtime deviceID", func() {
+			n, err := LoadConfLenient([]byte(`{"name": "mynet", "type": "ipoib", "ipoibAttrs": {"pkey": "0x8001"},
+				"runtimeConfig": {"deviceID": "0000:03:00.0"}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8001)))
+			Expect(n.DeviceID).To(Equal("0000:03:00.0"))
+		})
+		It("fails on malformed JSON", func() {
+			_, err := LoadConfLenient([]byte(`{"name": `))
+			Expect(err).To(HaveOccurred())
+		})
 	})
 })
@@ -0,0 +1,139 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+// AnnotationOverrides is the pod annotation overriding the netconf settings, a JSON object mapping the
+// network names to their overrides, e.g. {"mynet": {"pkey": "0x8002", "mtu": 4092, "mode": "connected"}}
+const AnnotationOverrides = "ipoib.cni.mellanox.com/overrides"
+
+// overrideArgs holds the CNI_ARGS overriding the netconf settings
+type overrideArgs struct {
+	cniTypes.CommonArgs
+	IPOIB_PKEY *types.Pkey                   //nolint:revive,stylecheck // CNI_ARGS key
+	IPOIB_MTU  cniTypes.UnmarshallableString //nolint:revive,stylecheck // CNI_ARGS key
+	IPOIB_MODE cniTypes.UnmarshallableString //nolint:revive,stylecheck // CNI_ARGS key
+}
+
+// ApplyOverrides applies the per pod overrides of pkey, MTU and mode on top of the netconf. In increasing
+// precedence they are taken from runtimeConfig.ipoib, the pod annotation in runtimeConfig and CNI_ARGS. The
+// annotation, which the tenants may set, is only honored by networks setting allowPodAnnotations. The
+// resulting settings are validated like the netconf ones.
+func ApplyOverrides(n *types.NetConf, cniArgs string) error {
+	var sources []*types.Overrides
+	if rc := n.RuntimeConfig; rc != nil {
+		if rc.Ipoib != nil {
+			sources = append(sources, rc.Ipoib)
+		}
+		annotations, err := annotationOverrides(rc.PodAnnotations, n.Name)
+		if err != nil {
+			return err
+		}
+		if annotations != nil {
+			if !n.AllowPodAnnotations {
+				return fmt.Errorf("annotation %q overrides network %q, which does not set allowPodAnnotations",
+					AnnotationOverrides, n.Name)
+			}
+			sources = append(sources, annotations)
+		}
+	}
+	args, err := argsOverrides(cniArgs)
+	if err != nil {
+		return err
+	}
+	sources = append(sources, args)
+
+	for _, o := range sources {
+		if o.Pkey != nil {
+			pkey := *o.Pkey
+			n.Pkey = &pkey
+		}
+		if o.MTU != 0 {
+			n.MTU = o.MTU
+		}
+		if o.Mode != "" {
+			n.Mode = o.Mode
+		}
+	}
+
+	var errs fieldErrors
+	errs.validateIpoibSettings(n, "")
+	if err = errs.err(); err != nil {
+		return fmt.Errorf("invalid override: %v", err)
+	}
+	slog.Debug("applied per pod overrides", "pkey", n.Pkey, "mtu", n.MTU, "mode", n.Mode)
+	if n.AutoCreateParent && n.Pkey == nil {
+		return fmt.Errorf("autoCreateParent requires pkey")
+	}
+	return nil
+}
+
+// annotationOverrides returns the overrides of network set in the pod annotations, or nil if there are none
+func annotationOverrides(annotations map[string]string, network string) (*types.Overrides, error) {
+	value, ok := annotations[AnnotationOverrides]
+	if !ok {
+		return nil, nil
+	}
+	var networks map[string]*types.Overrides
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&networks); err != nil {
+		return nil, fmt.Errorf("invalid annotation %q: %v", AnnotationOverrides, err)
+	}
+	return networks[network], nil
+}
+
+// argsOverrides returns the overrides set in CNI_ARGS
+func argsOverrides(cniArgs string) (*types.Overrides, error) {
+	// CNI_ARGS carry keys of other plugins as well
+	args := &overrideArgs{CommonArgs: cniTypes.CommonArgs{IgnoreUnknown: true}}
+	if err := cniTypes.LoadArgs(cniArgs, args); err != nil {
+		return nil, fmt.Errorf("failed to parse CNI_ARGS: %v", err)
+	}
+
+	o := &types.Overrides{Pkey: args.IPOIB_PKEY, Mode: string(args.IPOIB_MODE)}
+	if args.IPOIB_MTU != "" {
+		mtu, err := parseMTU(string(args.IPOIB_MTU))
+		if err != nil {
+			return nil, fmt.Errorf("invalid IPOIB_MTU: %v", err)
+		}
+		o.MTU = mtu
+	}
+	return o, nil
+}
+
+func parseMTU(value string) (int, error) {
+	mtu, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("mtu %q is not a number", value)
+	}
+	if mtu <= 0 {
+		return 0, fmt.Errorf("mtu %d must be positive", mtu)
+	}
+	return mtu, nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+var _ = Describe("Overrides", func() {
+	Context("Checking ApplyOverrides function", func() {
+		load := func(fields string) *types.NetConf {
+			n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "pkey": "0x8001",
+				"mtu": 2044, "mode": "datagram"` + fields + `}`))
+			Expect(err).NotTo(HaveOccurred())
+			return n
+		}
+
+		It("keeps the netconf without overrides", func() {
+			n := load("")
+			Expect(ApplyOverrides(n, "")).To(Succeed())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8001)))
+			Expect(n.MTU).To(Equal(2044))
+			Expect(n.Mode).To(Equal(types.IpoibModeDatagram))
+		})
+		It("applies runtimeConfig overrides", func() {
+			n := load(`, "runtimeConfig": {"ipoib": {"pkey": "0x8002", "mtu": 4092, "mode": "connected"}}`)
+			Expect(ApplyOverrides(n, "")).To(Succeed())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8002)))
+			Expect(n.MTU).To(Equal(4092))
+			Expect(n.Mode).To(Equal(types.IpoibModeConnected))
+		})
+		It("prefers the pod annotation over runtimeConfig overrides", func() {
+			n := load(`, "allowPodAnnotations": true, "runtimeConfig": {"ipoib": {"pkey": "0x8002", "mtu": 4092},
+				"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides": "{\"mynet\": {\"pkey\": \"0x8003\"}}",
+				"other.io/annotation": "value"}}`)
+			Expect(ApplyOverrides(n, "")).To(Succeed())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8003)))
+			Expect(n.MTU).To(Equal(4092))
+		})
+		It("prefers CNI_ARGS over the pod annotation", func() {
+			n := load(`, "allowPodAnnotations": true, "runtimeConfig": {"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides":
+				"{\"mynet\": {\"pkey\": \"0x8003\", \"mtu\": 4092, \"mode\": \"connected\"}}"}}`)
+			Expect(ApplyOverrides(n, "IgnoreUnknown=1;K8S_POD_NAMESPACE=ns;IPOIB_PKEY=0x8004;IPOIB_MTU=1500")).
+				To(Succeed())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8004)))
+			Expect(n.MTU).To(Equal(1500))
+			Expect(n.Mode).To(Equal(types.IpoibModeConnected))
+		})
+		It("Assuming pod annotation for another network", func() {
+			n := load(`, "runtimeConfig": {"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides": "{\"othernet\": {\"pkey\": \"0x8003\"}}"}}`)
+			Expect(ApplyOverrides(n, "")).To(Succeed())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8001)))
+		})
+		It("Assuming pod annotation for a network without allowPodAnnotations", func() {
+			n := load(`, "runtimeConfig": {"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides": "{\"mynet\": {\"pkey\": \"0x8003\"}}"}}`)
+			Expect(ApplyOverrides(n, "")).To(MatchError(ContainSubstring("does not set allowPodAnnotations")))
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8001)))
+		})
+		DescribeTable("rejects invalid overrides",
+			func(fields, cniArgs string) {
+				n := load(fields)
+				Expect(ApplyOverrides(n, cniArgs)).NotTo(Succeed())
+			},
+			Entry("invalid CNI_ARGS pkey", "", "IPOIB_PKEY=storage"),
+			Entry("reserved CNI_ARGS pkey", "", "IPOIB_PKEY=0x8000"),
+			Entry("invalid CNI_ARGS mtu", "", "IPOIB_MTU=big"),
+			Entry("negative CNI_ARGS mtu", "", "IPOIB_MTU=-1"),
+			Entry("invalid CNI_ARGS mode", "", "IPOIB_MODE=unreliable"),
+			Entry("invalid annotation pkey", `, "allowPodAnnotations": true, "runtimeConfig": {
+				"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides": "{\"mynet\": {\"pkey\": \"0x10000\"}}"}}`, ""),
+			Entry("invalid annotation mtu", `, "allowPodAnnotations": true, "runtimeConfig": {
+				"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides": "{\"mynet\": {\"mtu\": -1}}"}}`, ""),
+			Entry("unknown annotation field", `, "allowPodAnnotations": true, "runtimeConfig": {
+				"io.kubernetes.cri.pod-annotations": {
+				"ipoib.cni.mellanox.com/overrides": "{\"mynet\": {\"umcast\": false}}"}}`, ""),
+			Entry("annotation not JSON", `, "allowPodAnnotations": true, "runtimeConfig": {
+				"io.kubernetes.cri.pod-annotations": {"ipoib.cni.mellanox.com/overrides": "0x8003"}}`, ""),
+		)
+	})
+})
@@ -0,0 +1,252 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+// PartitionsFile holds the node partitions in the OpenSM partitions.conf syntax
+const PartitionsFile = "/etc/cni/ipoib.d/partitions.conf"
+
+const (
+	// defaultPartition is the partition OpenSM assigns pkey 0x7fff when no pkey is given
+	defaultPartition = "Default"
+	defaultPkey      = 0x7fff
+
+	membershipBoth = "both"
+)
+
+// partitionsFile is the node partitions file read by LoadConf
+var partitionsFile = PartitionsFile
+
+// Partition is a partition defined in an OpenSM partitions.conf file
+type Partition struct {
+	Name string
+	// Pkey is the 15 bit partition key, without the membership bit
+	Pkey types.Pkey
+	// IPoIB is set if the partition has the ipoib flag, i.e. the SM creates its IPoIB broadcast group
+	IPoIB bool
+	// DefMember is the membership of the ports listed without one, "full", "limited" or "both"
+	DefMember string
+	// Members maps the port GUIDs and keywords (e.g. ALL) of the partition to their membership
+	Members map[string]string
+}
+
+// Membership returns the partition membership of the port with portGUID, 16 lower case hex digits: the
+// membership given to the GUID, otherwise to all CA ports, otherwise the default membership of the partition
+func (p *Partition) Membership(portGUID string) string {
+	if membership, ok := p.PortMemberships()[portGUID]; ok {
+		return membership
+	}
+	membership := p.DefMember
+	for _, keyword := range []string{"ALL", "ALL_CAS", "SELF"} {
+		if m, ok := p.Members[keyword]; ok {
+			membership = m
+			break
+		}
+	}
+	return resolveBoth(membership)
+}
+
+// PortMemberships returns the memberships given to port GUIDs, keyed by 16 lower case hex digits
+func (p *Partition) PortMemberships() map[string]string {
+	memberships := map[string]string{}
+	for member, membership := range p.Members {
+		// the other members are port group keywords, the GUIDs are checked by isPartitionMember
+		if !strings.HasPrefix(member, "0x") && !strings.HasPrefix(member, "0X") {
+			continue
+		}
+		if guid, err := strconv.ParseUint(member[2:], 16, 64); err == nil {
+			memberships[fmt.Sprintf("%016x", guid)] = resolveBoth(membership)
+		}
+	}
+	return memberships
+}
+
+// resolveBoth returns the membership a port listed with membership gets, both resolves to full
+func resolveBoth(membership string) string {
+	if membership == membershipBoth {
+		return types.PkeyMembershipFull
+	}
+	return membership
+}
+
+// LoadPartitions reads the partitions file at path
+func LoadPartitions(path string) (map[string]*Partition, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // the path is set by the node admin
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions file: %v", err)
+	}
+	partitions, err := ParsePartitions(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse partitions file %q: %v", path, err)
+	}
+	return partitions, nil
+}
+
+// ParsePartitions parses partition definitions in the OpenSM partitions.conf syntax:
+//
+//	PartitionName[=PKey][,ipoib][,defmember=full|limited|both][,flag=value...] : [PortGUID[=membership],...] ;
+//
+// Comments start with '#', a definition ends with ';' and may span lines. Multicast group
+// definitions and IPoIB broadcast group flags are accepted and ignored.
+func ParsePartitions(data string) (map[string]*Partition, error) {
+	var stripped strings.Builder
+	for _, line := range strings.Split(data, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		stripped.WriteString(line)
+		stripped.WriteString("\n")
+	}
+
+	partitions := map[string]*Partition{}
+	line := 1
+	for _, def := range strings.Split(stripped.String(), ";") {
+		defLine := line + strings.Count(def, "\n") - strings.Count(strings.TrimLeft(def, " \t\n"), "\n")
+		line += strings.Count(def, "\n")
+		if strings.TrimSpace(def) == "" {
+			continue
+		}
+
+		p, err := parsePartition(def)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", defLine, err)
+		}
+		if _, ok := partitions[p.Name]; ok {
+			return nil, fmt.Errorf("line %d: partition %q is defined more than once", defLine, p.Name)
+		}
+		partitions[p.Name] = p
+	}
+	return partitions, nil
+}
+
+// parsePartition parses a single partition definition, without the terminating ';'
+func parsePartition(def string) (*Partition, error) {
+	header, members, ok := strings.Cut(def, ":")
+	if !ok {
+		return nil, fmt.Errorf("missing ':' in partition definition %q", strings.TrimSpace(def))
+	}
+
+	fields := strings.Split(header, ",")
+	name, pkey, hasPkey := strings.Cut(fields[0], "=")
+	p := &Partition{
+		Name:      strings.TrimSpace(name),
+		DefMember: types.PkeyMembershipLimited,
+		Members:   map[string]string{},
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("missing partition name")
+	}
+
+	switch {
+	case hasPkey:
+		num, err := strconv.ParseUint(strings.TrimSpace(pkey), 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pkey %q of partition %q", strings.TrimSpace(pkey), p.Name)
+		}
+		// OpenSM only uses the low 15 bits, the membership is given per port
+		p.Pkey = types.Pkey(num & types.PkeyMask)
+	case p.Name == defaultPartition:
+		p.Pkey = defaultPkey
+	default:
+		return nil, fmt.Errorf("missing pkey of partition %q", p.Name)
+	}
+
+	for _, field := range fields[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch strings.TrimSpace(key) {
+		case "ipoib":
+			p.IPoIB = true
+		case "defmember":
+			value = strings.TrimSpace(value)
+			if !isMembership(value) {
+				return nil, fmt.Errorf("invalid defmember %q of partition %q", value, p.Name)
+			}
+			p.DefMember = value
+		}
+	}
+
+	for _, member := range strings.Split(members, ",") {
+		port, membership, hasMembership := strings.Cut(strings.TrimSpace(member), "=")
+		port = strings.TrimSpace(port)
+		if port == "" || !isPartitionMember(port) {
+			// multicast group definitions and their flags
+			continue
+		}
+		membership = strings.TrimSpace(membership)
+		if !hasMembership {
+			membership = p.DefMember
+		} else if !isMembership(membership) {
+			return nil, fmt.Errorf("invalid membership %q of %s in partition %q", membership, port, p.Name)
+		}
+		p.Members[port] = membership
+	}
+	return p, nil
+}
+
+// isPartitionMember returns true if s is a port GUID or one of the OpenSM port group keywords
+func isPartitionMember(s string) bool {
+	switch s {
+	case "ALL", "ALL_CAS", "ALL_SWITCHES", "ALL_ROUTERS", "SELF":
+		return true
+	}
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return false
+	}
+	_, err := strconv.ParseUint(s[2:], 16, 64)
+	return err == nil
+}
+
+func isMembership(s string) bool {
+	return s == types.PkeyMembershipFull || s == types.PkeyMembershipLimited || s == membershipBoth
+}
+
+// applyPartition sets the pkey and the membership of the netconf from the named partition
+func applyPartition(n *types.NetConf, path string) error {
+	partitions, err := LoadPartitions(path)
+	if err != nil {
+		return err
+	}
+	p, ok := partitions[n.Partition]
+	if !ok {
+		return fmt.Errorf("partition %q is not defined in %q", n.Partition, path)
+	}
+	if !p.IPoIB {
+		return fmt.Errorf("partition %q does not have the ipoib flag, it has no IPoIB broadcast group", n.Partition)
+	}
+	if n.Pkey != nil {
+		// an explicit pkey may only repeat the partition pkey, its membership bit is kept
+		if *n.Pkey&types.PkeyMask != p.Pkey {
+			return fmt.Errorf("pkey %s conflicts with partition %q pkey %s", *n.Pkey, n.Partition, p.Pkey)
+		}
+		return nil
+	}
+
+	pkey := p.Pkey
+	n.Pkey = &pkey
+	if n.PkeyMembership == "" {
+		// the membership of the ports listed by GUID is resolved once the master is known
+		n.PkeyMembership = p.Membership("")
+		n.PortMemberships = p.PortMemberships()
+	}
+	return nil
+}
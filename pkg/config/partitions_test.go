@@ -0,0 +1,154 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+const testPartitions = `# partitions managed by the fabric team
+Default=0x7fff, ipoib : ALL=full ;
+
+storage=0x8001, ipoib, mtu=5, rate=7, defmember=full :
+    0x0c42a10300175a3c,
+    0x0c42a10300175a3d=limited ;
+mpi=0x0002, ipoib : ALL_CAS, SELF=full ; # SELF is the SM port
+mgmt=0x0003, ipoib, defmember=both : ALL, mgid=ff12:401b::0707, sl=1 ;
+nfs=0x0004 : ALL=full ;
+`
+
+var _ = Describe("Partitions", func() {
+	Context("Checking ParsePartitions function", func() {
+		It("parses the OpenSM syntax", func() {
+			partitions, err := ParsePartitions(testPartitions)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(partitions).To(HaveLen(5))
+
+			storage := partitions["storage"]
+			Expect(storage.Pkey).To(Equal(types.Pkey(0x0001)))
+			Expect(storage.IPoIB).To(BeTrue())
+			Expect(storage.DefMember).To(Equal("full"))
+			Expect(storage.Members).To(Equal(map[string]string{
+				"0x0c42a10300175a3c": "full",
+				"0x0c42a10300175a3d": "limited",
+			}))
+
+			Expect(partitions["Default"].Pkey).To(Equal(types.Pkey(0x7fff)))
+			Expect(partitions["mgmt"].Members).To(Equal(map[string]string{"ALL": "both"}))
+			Expect(partitions["nfs"].IPoIB).To(BeFalse())
+		})
+		It("gives the default pkey to the Default partition", func() {
+			partitions, err := ParsePartitions("Default : ALL ;")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(partitions["Default"].Pkey).To(Equal(types.Pkey(0x7fff)))
+		})
+		DescribeTable("resolves the membership of the node ports",
+			func(name, portGUID, expected string) {
+				partitions, err := ParsePartitions(testPartitions)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(partitions[name].Membership(portGUID)).To(Equal(expected))
+			},
+			Entry("ALL member", "Default", "", "full"),
+			Entry("default membership", "storage", "", "full"),
+			Entry("GUID member", "storage", "0c42a10300175a3d", "limited"),
+			Entry("GUID member with the default membership", "storage", "0c42a10300175a3c", "full"),
+			Entry("GUID not listed", "mpi", "0c42a10300175a3d", "limited"),
+			Entry("ALL_CAS member", "mpi", "", "limited"),
+			Entry("both memberships", "mgmt", "", "full"),
+		)
+		It("returns the memberships of the port GUIDs", func() {
+			partitions, err := ParsePartitions("storage=0x1, ipoib : ALL=full, 0x0C42A10300175A3D=both, " +
+				"0x2=limited ;")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(partitions["storage"].PortMemberships()).To(Equal(map[string]string{
+				"0c42a10300175a3d": "full",
+				"0000000000000002": "limited",
+			}))
+		})
+		DescribeTable("rejects invalid definitions",
+			func(data, message string) {
+				_, err := ParsePartitions(data)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("missing ports list", "storage=0x0001, ipoib ;", "missing ':'"),
+			Entry("missing name", "=0x0001 : ALL ;", "missing partition name"),
+			Entry("missing pkey", "storage, ipoib : ALL ;", "missing pkey"),
+			Entry("invalid pkey", "storage=0x10000 : ALL ;", "invalid pkey"),
+			Entry("invalid defmember", "storage=0x1, defmember=some : ALL ;", "invalid defmember"),
+			Entry("invalid membership", "storage=0x1 : ALL=some ;", "invalid membership"),
+			Entry("duplicated partition", "a=0x1 : ALL ;\n\na=0x2 : ALL ;", "line 3: partition \"a\""),
+		)
+	})
+
+	Context("Checking LoadConf function with partition", func() {
+		BeforeEach(func() {
+			path := filepath.Join(GinkgoT().TempDir(), "partitions.conf")
+			Expect(os.WriteFile(path, []byte(testPartitions), 0o600)).To(Succeed())
+			partitionsFile = path
+			DeferCleanup(func() { partitionsFile = PartitionsFile })
+		})
+
+		It("resolves the pkey and membership of the partition", func() {
+			n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "partition": "mpi"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x0002)))
+			Expect(n.PkeyMembership).To(Equal("limited"))
+			Expect(n.PortMemberships).To(BeEmpty())
+		})
+		It("keeps the memberships of the port GUIDs of the partition", func() {
+			n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+				"partition": "storage"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.PkeyMembership).To(Equal("full"))
+			Expect(n.PortMemberships).To(HaveKeyWithValue("0c42a10300175a3d", "limited"))
+		})
+		It("prefers the netconf pkeyMembership", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "partition": "mpi",
+				"pkeyMembership": "full"}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.PkeyMembership).To(Equal("full"))
+		})
+		It("accepts the same pkey", func() {
+			conf := []byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "partition": "storage",
+				"pkey": "0x8001"}`)
+			n, _, err := LoadConf(conf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*n.Pkey).To(Equal(types.Pkey(0x8001)))
+		})
+		DescribeTable("rejects the partition",
+			func(fields string) {
+				_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", ` + fields + `}`))
+				Expect(err).To(HaveOccurred())
+			},
+			Entry("undefined partition", `"partition": "backup"`),
+			Entry("partition without ipoib flag", `"partition": "nfs"`),
+			Entry("conflicting pkey", `"partition": "storage", "pkey": "0x8002"`),
+		)
+		It("fails without partitions file", func() {
+			partitionsFile = filepath.Join(GinkgoT().TempDir(), "missing.conf")
+			_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "partition": "mpi"}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,106 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+// PolicyFile holds the node tenant policy
+const PolicyFile = "/etc/cni/ipoib.d/policy.json"
+
+// anyNamespace is the policy key which applies to namespaces without their own policy
+const anyNamespace = "*"
+
+// policyFile is the node tenant policy file read by LoadPolicy
+var policyFile = PolicyFile
+
+// tenantPolicies maps Kubernetes namespaces to their tenant policy
+type tenantPolicies struct {
+	Namespaces map[string]*types.TenantPolicy `json:"namespaces"`
+}
+
+// k8sArgs holds the Kubernetes CNI_ARGS passed by the runtime
+type k8sArgs struct {
+	cniTypes.CommonArgs
+	K8S_POD_NAMESPACE cniTypes.UnmarshallableString //nolint:revive,stylecheck // CNI_ARGS key
+}
+
+// LoadPolicy sets the tenant policy of the pod namespace, taken from CNI_ARGS, on the netconf. Without
+// a node policy file nothing is restricted. With a policy file, namespaces without a policy use the "*"
+// policy, or are rejected if there is none.
+func LoadPolicy(n *types.NetConf, cniArgs string) error {
+	data, err := os.ReadFile(policyFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read tenant policy: %v", err)
+	}
+
+	policies := &tenantPolicies{}
+	if err = json.Unmarshal(data, policies); err != nil {
+		return fmt.Errorf("failed to load tenant policy %q: %v", policyFile, err)
+	}
+	for namespace, policy := range policies.Namespaces {
+		if err = validatePolicy(policy); err != nil {
+			return fmt.Errorf("invalid tenant policy of namespace %q: %v", namespace, err)
+		}
+	}
+
+	// CNI_ARGS carry keys of other plugins as well
+	args := &k8sArgs{CommonArgs: cniTypes.CommonArgs{IgnoreUnknown: true}}
+	if err = cniTypes.LoadArgs(cniArgs, args); err != nil {
+		return fmt.Errorf("failed to parse CNI_ARGS: %v", err)
+	}
+	namespace := string(args.K8S_POD_NAMESPACE)
+
+	policy, ok := policies.Namespaces[namespace]
+	if !ok {
+		policy, ok = policies.Namespaces[anyNamespace]
+	}
+	if !ok {
+		return cniTypes.NewError(types.ErrPolicyViolation, "tenant policy violation",
+			fmt.Sprintf("namespace %q is not allowed to use IPoIB networks on this node", namespace))
+	}
+
+	policy.Namespace = namespace
+	n.Policy = policy
+	return nil
+}
+
+// validatePolicy checks the pkeys and the MTU range of a tenant policy
+func validatePolicy(policy *types.TenantPolicy) error {
+	if policy == nil {
+		return fmt.Errorf("policy is empty")
+	}
+	for _, pkey := range policy.Pkeys {
+		if err := validatePkey(pkey); err != nil {
+			return err
+		}
+	}
+	if policy.MTU != nil && policy.MTU.Max != 0 && policy.MTU.Min > policy.MTU.Max {
+		return fmt.Errorf("invalid mtu range: min %d is larger than max %d", policy.MTU.Min, policy.MTU.Max)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+var _ = Describe("Policy", func() {
+	Context("Checking LoadPolicy function", func() {
+		var n *types.NetConf
+
+		writePolicy := func(policy string) {
+			path := filepath.Join(GinkgoT().TempDir(), "policy.json")
+			Expect(os.WriteFile(path, []byte(policy), 0o600)).To(Succeed())
+			policyFile = path
+		}
+
+		BeforeEach(func() {
+			n = &types.NetConf{Master: "ib0"}
+			DeferCleanup(func() { policyFile = PolicyFile })
+		})
+
+		It("does not restrict without policy file", func() {
+			policyFile = filepath.Join(GinkgoT().TempDir(), "missing.json")
+			Expect(LoadPolicy(n, "K8S_POD_NAMESPACE=tenant-a")).To(Succeed())
+			Expect(n.Policy).To(BeNil())
+		})
+		It("sets the policy of the pod namespace", func() {
+			writePolicy(`{"namespaces": {
+				"tenant-a": {"masters": ["ib1"], "pkeys": ["0x8001"], "mtu": {"min": 1500, "max": 4092}},
+				"*": {"masters": ["ib0"]}
+			}}`)
+			Expect(LoadPolicy(n, "IgnoreUnknown=1;K8S_POD_NAMESPACE=tenant-a;K8S_POD_NAME=pod")).To(Succeed())
+			Expect(n.Policy.Namespace).To(Equal("tenant-a"))
+			Expect(n.Policy.Masters).To(Equal([]string{"ib1"}))
+			Expect(n.Policy.Pkeys).To(Equal([]types.Pkey{0x8001}))
+			Expect(n.Policy.MTU).To(Equal(&types.MTURange{Min: 1500, Max: 4092}))
+		})
+		It("ignores unknown CNI_ARGS", func() {
+			writePolicy(`{"namespaces": {"tenant-a": {}}}`)
+			Expect(LoadPolicy(n, "K8S_POD_NAMESPACE=tenant-a;K8S_POD_UID=1234")).To(Succeed())
+		})
+		It("falls back to the policy of any namespace", func() {
+			writePolicy(`{"namespaces": {"tenant-a": {"masters": ["ib1"]}, "*": {"masters": ["ib0"]}}}`)
+			Expect(LoadPolicy(n, "K8S_POD_NAMESPACE=tenant-b")).To(Succeed())
+			Expect(n.Policy.Namespace).To(Equal("tenant-b"))
+			Expect(n.Policy.Masters).To(Equal([]string{"ib0"}))
+		})
+		It("rejects namespace without policy", func() {
+			writePolicy(`{"namespaces": {"tenant-a": {"masters": ["ib1"]}}}`)
+			err := LoadPolicy(n, "K8S_POD_NAMESPACE=tenant-b")
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrPolicyViolation))
+		})
+		DescribeTable("rejects invalid policy file",
+			func(policy string) {
+				writePolicy(policy)
+				Expect(LoadPolicy(n, "K8S_POD_NAMESPACE=tenant-a")).NotTo(Succeed())
+			},
+			Entry("invalid json", `{"namespaces": [`),
+			Entry("empty policy", `{"namespaces": {"tenant-a": null}}`),
+			Entry("invalid pkey", `{"namespaces": {"tenant-a": {"pkeys": ["0x8000"]}}}`),
+			Entry("invalid mtu range", `{"namespaces": {"tenant-a": {"mtu": {"min": 4092, "max": 2044}}}}`),
+		)
+	})
+})
@@ -0,0 +1,410 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is the JSON schema of the netconf, the netconf is validated against it so `ipoib schema` lints
+// with the rules of the plugin
+//
+//go:embed schema.json
+var Schema []byte
+
+// netconfSchema is the parsed Schema, an invalid one fails the plugin on start
+var netconfSchema = mustLoadSchema(Schema)
+
+// schemaNode is the subset of the JSON schema keywords used by the netconf schema. loadSchema rejects the
+// other keywords, so that the schema never says more than validateSchema enforces.
+type schemaNode struct {
+	// $schema, $id and title annotate the schema, they do not validate
+	SchemaURI            string                 `json:"$schema"`
+	ID                   string                 `json:"$id"`
+	Title                string                 `json:"title"`
+	Ref                  string                 `json:"$ref"`
+	Type                 string                 `json:"type"`
+	Const                json.RawMessage        `json:"const"`
+	Enum                 []json.RawMessage      `json:"enum"`
+	Not                  *schemaNode            `json:"not"`
+	OneOf                []*schemaNode          `json:"oneOf"`
+	Minimum              *json.Number           `json:"minimum"`
+	Maximum              *json.Number           `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	Items                *schemaNode            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	UniqueItems          bool                   `json:"uniqueItems"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Defs                 map[string]*schemaNode `json:"$defs"`
+
+	// ref, pattern, additional and closed are resolved once by loadSchema
+	ref        *schemaNode
+	pattern    *regexp.Regexp
+	additional *schemaNode
+	closed     bool
+}
+
+func mustLoadSchema(data []byte) *schemaNode {
+	root, err := loadSchema(data)
+	if err != nil {
+		panic(err)
+	}
+	return root
+}
+
+// loadSchema parses a netconf schema, it fails on the keywords validateSchema does not implement
+func loadSchema(data []byte) (*schemaNode, error) {
+	root, err := decodeSchemaNode(data)
+	if err == nil {
+		err = root.resolve(root, "#")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load netconf schema: %v", err)
+	}
+	return root, nil
+}
+
+func decodeSchemaNode(data []byte) (*schemaNode, error) {
+	node := &schemaNode{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// resolve resolves the reference, compiles the pattern and decodes the additional properties of the node at
+// path, and of its subschemas
+func (node *schemaNode) resolve(root *schemaNode, path string) error {
+	if node.Ref != "" {
+		name, ok := strings.CutPrefix(node.Ref, "#/$defs/")
+		if node.ref = root.Defs[name]; !ok || node.ref == nil {
+			return fmt.Errorf("%s: unresolved $ref %q", path, node.Ref)
+		}
+	}
+	if node.Pattern != "" {
+		var err error
+		if node.pattern, err = regexp.Compile(node.Pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern: %v", path, err)
+		}
+	}
+	switch string(node.AdditionalProperties) {
+	case "", "true":
+	case "false":
+		node.closed = true
+	default:
+		var err error
+		if node.additional, err = decodeSchemaNode(node.AdditionalProperties); err != nil {
+			return fmt.Errorf("%s/additionalProperties: %v", path, err)
+		}
+	}
+
+	subschemas := map[string]*schemaNode{"not": node.Not, "items": node.Items, "additionalProperties": node.additional}
+	for i, option := range node.OneOf {
+		subschemas[fmt.Sprintf("oneOf/%d", i)] = option
+	}
+	for name, property := range node.Properties {
+		subschemas["properties/"+name] = property
+	}
+	for name, def := range node.Defs {
+		subschemas["$defs/"+name] = def
+	}
+	for name, subschema := range subschemas {
+		if subschema == nil {
+			continue
+		}
+		if err := subschema.resolve(root, path+"/"+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeJSON decodes data keeping the numbers as json.Number, so integers are told apart from floats
+func decodeJSON(data []byte) (any, error) {
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// validateSchema adds an error for each violation of the schema node by the JSON value at path
+func (errs *fieldErrors) validateSchema(node *schemaNode, path string, value any) {
+	// the keywords next to $ref apply as well
+	if node.ref != nil {
+		errs.validateSchema(node.ref, path, value)
+	}
+	if node.Type != "" && jsonType(value, node.Type) != node.Type {
+		errs.add(path, "expected %s, got %s", node.Type, jsonType(value, node.Type))
+		return
+	}
+	if node.Const != nil && !jsonEqual(node.Const, value) {
+		errs.add(path, "must be %s, got %s", node.Const, jsonString(value))
+	}
+	if node.Enum != nil && !enumContains(node.Enum, value) {
+		errs.add(path, "must be one of %s, got %s", enumString(node.Enum), jsonString(value))
+	}
+	if node.Not != nil && matchesSchema(node.Not, value) {
+		errs.add(path, "%s is not allowed", jsonString(value))
+	}
+	if node.OneOf != nil {
+		matches := 0
+		for _, option := range node.OneOf {
+			if matchesSchema(option, value) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs.add(path, "%s is not valid", jsonString(value))
+		}
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		errs.validateNumber(node, path, v)
+	case string:
+		errs.validateString(node, path, v)
+	case []any:
+		errs.validateArray(node, path, v)
+	case map[string]any:
+		errs.validateObject(node, path, v)
+	}
+}
+
+func (errs *fieldErrors) validateNumber(node *schemaNode, path string, value json.Number) {
+	f, _ := value.Float64()
+	if node.Minimum != nil {
+		if minimum, _ := node.Minimum.Float64(); f < minimum {
+			errs.add(path, "must be at least %s, got %s", *node.Minimum, value)
+		}
+	}
+	if node.Maximum != nil {
+		if maximum, _ := node.Maximum.Float64(); f > maximum {
+			errs.add(path, "must be at most %s, got %s", *node.Maximum, value)
+		}
+	}
+}
+
+func (errs *fieldErrors) validateString(node *schemaNode, path, value string) {
+	length := utf8.RuneCountInString(value)
+	if node.MinLength != nil && length < *node.MinLength {
+		if *node.MinLength == 1 {
+			errs.add(path, "must not be empty")
+		} else {
+			errs.add(path, "must be at least %d characters long, got %q", *node.MinLength, value)
+		}
+	}
+	if node.MaxLength != nil && length > *node.MaxLength {
+		errs.add(path, "must be at most %d characters long, got %q", *node.MaxLength, value)
+	}
+	if node.pattern != nil && !node.pattern.MatchString(value) {
+		errs.add(path, "must match %q, got %q", node.Pattern, value)
+	}
+}
+
+func (errs *fieldErrors) validateArray(node *schemaNode, path string, value []any) {
+	if node.MinItems != nil && len(value) < *node.MinItems {
+		errs.add(path, "must have at least %d items, got %d", *node.MinItems, len(value))
+	}
+	seen := make(map[string]bool, len(value))
+	for i, item := range value {
+		if node.Items != nil {
+			errs.validateSchema(node.Items, fmt.Sprintf("%s[%d]", path, i), item)
+		}
+		if node.UniqueItems {
+			key := jsonString(item)
+			if seen[key] {
+				errs.add(fmt.Sprintf("%s[%d]", path, i), "%s is duplicated", key)
+			}
+			seen[key] = true
+		}
+	}
+}
+
+func (errs *fieldErrors) validateObject(node *schemaNode, path string, value map[string]any) {
+	field := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	for _, key := range node.Required {
+		if _, ok := value[key]; !ok {
+			errs.add(field(key), "is required")
+		}
+	}
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child, ok := node.Properties[key]
+		switch {
+		case ok:
+			errs.validateSchema(child, field(key), value[key])
+		case node.additional != nil:
+			errs.validateSchema(node.additional, field(key), value[key])
+		case node.closed:
+			message := "unknown field"
+			if suggestion := closestField(key, node.Properties); suggestion != "" {
+				message = fmt.Sprintf("unknown field, did you mean %q?", suggestion)
+			}
+			*errs = append(*errs, &FieldError{Field: field(key), Message: message, unknown: true})
+		}
+	}
+}
+
+// matchesSchema returns whether the JSON value is valid against the schema node
+func matchesSchema(node *schemaNode, value any) bool {
+	var errs fieldErrors
+	errs.validateSchema(node, "", value)
+	return len(errs) == 0
+}
+
+// jsonType returns the JSON schema type of the value, an integral number is an "integer" if that is the
+// expected type and a "number" otherwise
+func jsonType(value any, expected string) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil && expected == "integer" {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonString returns the value encoded as JSON
+func jsonString(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// jsonEqual returns whether the JSON value of the schema equals the value
+func jsonEqual(schemaValue json.RawMessage, value any) bool {
+	var compact bytes.Buffer
+	if json.Compact(&compact, schemaValue) != nil {
+		return false
+	}
+	return compact.String() == jsonString(value)
+}
+
+func enumContains(enum []json.RawMessage, value any) bool {
+	for _, v := range enum {
+		if jsonEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumString formats the enum values as "a", "b" or "c"
+func enumString(enum []json.RawMessage) string {
+	values := make([]string, 0, len(enum))
+	for _, v := range enum {
+		values = append(values, string(v))
+	}
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+// withSchemaErrors returns the schema errors of the fields not reported by the checks of the plugin, followed
+// by the errors of the checks, whose messages are more specific
+func (errs fieldErrors) withSchemaErrors(schemaErrs fieldErrors) fieldErrors {
+	related := func(a, b string) bool {
+		return a == b || strings.HasPrefix(b, a+".") || strings.HasPrefix(b, a+"[")
+	}
+	merged := make(fieldErrors, 0, len(schemaErrs)+len(errs))
+	for _, schemaErr := range schemaErrs {
+		reported := false
+		for _, err := range errs {
+			if related(schemaErr.Field, err.Field) || related(err.Field, schemaErr.Field) {
+				reported = true
+				break
+			}
+		}
+		if !reported {
+			merged = append(merged, schemaErr)
+		}
+	}
+	return append(merged, errs...)
+}
+
+// closestField returns the property closest to the misspelled key, or an empty string if none is close
+func closestField(key string, properties map[string]*schemaNode) string {
+	const maxDistance = 2
+	closest, closestDistance := "", maxDistance+1
+	for property := range properties {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(property))
+		if distance < closestDistance || (distance == closestDistance && property < closest) {
+			closest, closestDistance = property, distance
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,191 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/logging"
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+var _ = Describe("Schema", func() {
+	root := netconfSchema
+
+	// schemaAccepts returns whether the value is valid against the schema node
+	schemaAccepts := func(node *schemaNode, value any) bool {
+		data, err := json.Marshal(value)
+		Expect(err).NotTo(HaveOccurred())
+		decoded, err := decodeJSON(data)
+		Expect(err).NotTo(HaveOccurred())
+		return matchesSchema(node, decoded)
+	}
+
+	// The keywords decoded by loadSchema, except the annotations, must be enforced by validateSchema
+	Context("Checking the supported keywords", func() {
+		annotations := []string{"$schema", "$id", "title"}
+		// tested are the keywords covered by the table below, $defs only holds the subschemas of $ref
+		tested := []string{"$ref", "$defs", "type", "const", "enum", "not", "oneOf", "minimum", "maximum",
+			"minLength", "maxLength", "pattern", "items", "minItems", "uniqueItems", "required", "properties",
+			"additionalProperties"}
+
+		It("enforces every keyword it loads", func() {
+			Expect(append(tested, annotations...)).To(ConsistOf(jsonFields(reflect.TypeOf(schemaNode{}))))
+		})
+		DescribeTable("enforces the keyword",
+			func(schema string, valid, invalid string) {
+				node, err := loadSchema([]byte(schema))
+				Expect(err).NotTo(HaveOccurred())
+				validValue, err := decodeJSON([]byte(valid))
+				Expect(err).NotTo(HaveOccurred())
+				invalidValue, err := decodeJSON([]byte(invalid))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(matchesSchema(node, validValue)).To(BeTrue())
+				Expect(matchesSchema(node, invalidValue)).To(BeFalse())
+			},
+			Entry("$ref with sibling keywords", `{"$ref": "#/$defs/name", "maxLength": 3,
+				"$defs": {"name": {"type": "string", "minLength": 1}}}`, `"ib0"`, `"ib00"`),
+			Entry("$ref", `{"$ref": "#/$defs/name", "$defs": {"name": {"minLength": 1}}}`, `"ib0"`, `""`),
+			Entry("type", `{"type": "integer"}`, `1`, `1.5`),
+			Entry("const", `{"const": "ipoib"}`, `"ipoib"`, `"macvlan"`),
+			Entry("enum", `{"enum": ["full", "limited"]}`, `"full"`, `"both"`),
+			Entry("not", `{"not": {"const": "dhcp"}}`, `"static"`, `"dhcp"`),
+			Entry("oneOf", `{"oneOf": [{"type": "integer"}, {"type": "string"}]}`, `"0x8001"`, `true`),
+			Entry("minimum", `{"minimum": 1}`, `1`, `0`),
+			Entry("maximum", `{"maximum": 65535}`, `65535`, `65536`),
+			Entry("minLength", `{"minLength": 1}`, `"a"`, `""`),
+			Entry("maxLength", `{"maxLength": 15}`, `"ib0"`, `"ibp129s0f0np0.1234"`),
+			Entry("pattern", `{"pattern": "^ib"}`, `"ib0"`, `"eth0"`),
+			Entry("items", `{"items": {"type": "string"}}`, `["ib0"]`, `[0]`),
+			Entry("minItems", `{"minItems": 1}`, `["ib0"]`, `[]`),
+			Entry("uniqueItems", `{"uniqueItems": true}`, `["ib0", "ib1"]`, `["ib0", "ib0"]`),
+			Entry("required", `{"required": ["type"]}`, `{"type": "ipoib"}`, `{}`),
+			Entry("properties", `{"properties": {"mtu": {"type": "integer"}}}`, `{"mtu": 4092}`, `{"mtu": "4092"}`),
+			Entry("closed additionalProperties", `{"additionalProperties": false, "properties": {"mtu": {}}}`,
+				`{"mtu": 4092}`, `{"vf": 0}`),
+			Entry("additionalProperties schema", `{"additionalProperties": {"type": "boolean"}}`, `{"ips": true}`,
+				`{"ips": "yes"}`),
+		)
+		DescribeTable("rejects a schema the validator does not implement",
+			func(schema, message string) {
+				_, err := loadSchema([]byte(schema))
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("unsupported keyword", `{"type": "string", "format": "ipv4"}`, `unknown field "format"`),
+			Entry("unsupported nested keyword", `{"properties": {"mtu": {"exclusiveMinimum": 0}}}`,
+				`unknown field "exclusiveMinimum"`),
+			Entry("unsupported keyword in additionalProperties", `{"additionalProperties": {"format": "ipv4"}}`,
+				`unknown field "format"`),
+			Entry("unresolved $ref", `{"properties": {"mtu": {"$ref": "#/$defs/mtu"}}}`,
+				`#/properties/mtu: unresolved $ref "#/$defs/mtu"`),
+			Entry("invalid pattern", `{"pattern": "^(ib"}`, "#: invalid pattern"),
+		)
+		It("loads the embedded schema, all of whose keywords are supported", func() {
+			_, err := loadSchema(Schema)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	// goAccepts returns whether the check of the plugin accepts the value
+	goAccepts := func(check func(*fieldErrors)) bool {
+		var errs fieldErrors
+		check(&errs)
+		return len(errs) == 0
+	}
+
+	// The settings of the node defaults, the overrides file and the tenant policy are checked by the Go
+	// validators only, they must accept the same values as the schema. Zero and empty values mean unset there.
+	Context("Checking the validators of the settings not covered by the schema", func() {
+		It("agree with the schema on mtu", func() {
+			for _, mtu := range []int{-1, 1, minMTU - 1, minMTU, types.MaxDatagramMTU, types.MaxConnectedMTU,
+				types.MaxConnectedMTU + 1} {
+				Expect(goAccepts(func(errs *fieldErrors) { errs.validateMTU("mtu", mtu) })).
+					To(Equal(schemaAccepts(root.Defs["mtu"], mtu)), "mtu %d", mtu)
+			}
+		})
+		It("agree with the schema on pkey", func() {
+			for _, pkey := range []int{-1, 1, 0x7fff, 0x8000, 0x8001, types.MaxPkey, types.MaxPkey + 1} {
+				Expect(validatePkey(types.Pkey(pkey)) == nil).
+					To(Equal(schemaAccepts(root.Defs["pkey"], pkey)), "pkey %#x", pkey)
+			}
+		})
+		It("agree with the schema on mode", func() {
+			for _, mode := range []string{types.IpoibModeDatagram, types.IpoibModeConnected, "Connected", "rc"} {
+				Expect(goAccepts(func(errs *fieldErrors) { errs.validateMode("mode", mode) })).
+					To(Equal(schemaAccepts(root.Defs["mode"], mode)), "mode %q", mode)
+			}
+		})
+		It("agree with the schema on mtuPolicy", func() {
+			for _, policy := range []string{types.MTUPolicyStrict, types.MTUPolicyClamp, "fail"} {
+				Expect(goAccepts(func(errs *fieldErrors) { errs.validateMTUPolicy(policy) })).
+					To(Equal(schemaAccepts(root.Properties["mtuPolicy"], policy)), "mtuPolicy %q", policy)
+			}
+		})
+		It("agree with the schema on logLevel", func() {
+			for _, level := range []string{logging.LevelDebug, logging.LevelInfo, logging.LevelWarning,
+				logging.LevelError, "verbose"} {
+				Expect(logging.ValidateLevel(level) == nil).
+					To(Equal(schemaAccepts(root.Properties["logLevel"], level)), "logLevel %q", level)
+			}
+		})
+		It("agree with the schema on interface names", func() {
+			for _, name := range []string{"", "ib0", "ibp129s0f0.8001", "ibp129s0f0n.8001", ".", "..", "ib/0",
+				"ib0:1", "ib 0", "ib\t0", "ib\r0"} {
+				Expect(ValidateIfName(name) == nil).
+					To(Equal(schemaAccepts(root.Defs["ifName"], name)), "interface name %q", name)
+			}
+		})
+	})
+
+	Context("Checking the netconf validation", func() {
+		validate := func(conf string) []*FieldError {
+			_, fields := ValidateConf([]byte(conf))
+			return fields
+		}
+
+		It("rejects the values the schema does not allow", func() {
+			Expect(validate(`{"name": "", "type": "ipoib", "masterPci": "0000:03:00.0", "masterPort": 0,
+				"ipam": {"type": "dhcp"}}`)).To(ConsistOf(
+				&FieldError{Field: "ipam.type", Message: `"dhcp" is not allowed`},
+				&FieldError{Field: "masterPort", Message: "must be at least 1, got 0"},
+				&FieldError{Field: "name", Message: "must not be empty"},
+			))
+		})
+		It("requires the type", func() {
+			Expect(validate(`{"name": "mynet", "master": "ib0"}`)).
+				To(ConsistOf(&FieldError{Field: "type", Message: "is required"}))
+		})
+		It("reports a field once, with the message of the plugin check", func() {
+			Expect(validate(`{"name": "mynet", "type": "ipoib", "master": "ib0", "mtu": 70000}`)).
+				To(ConsistOf(&FieldError{Field: "mtu", Message: "must be in range 68-65520, got 70000"}))
+		})
+		It("reports the type errors of the additional properties", func() {
+			Expect(validate(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+				"capabilities": {"ips": "yes"}}`)).
+				To(ConsistOf(&FieldError{Field: "capabilities.ips", Message: "expected bool, got string"}))
+		})
+		It("accepts a netconf valid against the schema", func() {
+			Expect(validate(`{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib",
+				"masterPci": "0000:03:00.0", "masterPort": 2, "pkey": "0x8001", "mtu": 4092,
+				"ipam": {"type": "host-local"}}`)).To(BeEmpty())
+		})
+	})
+})
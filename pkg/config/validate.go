@@ -0,0 +1,350 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+
+	"github.com/Mellanox/ipoib-cni/pkg/logging"
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+const (
+	// minMTU is the smallest MTU IPv4 works with
+	minMTU = 68
+	// pkeyParentSuffixLen is the length of the ".8001" suffix of a pkey parent interface name
+	pkeyParentSuffixLen = 5
+)
+
+// FieldError is a validation error of a single netconf field
+type FieldError struct {
+	// Field is the path of the field, e.g. "ipoibAttrs.pkey" or "masters[1]"
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	// unknown is set for a field the schema does not define, which only ValidateConf rejects
+	unknown bool
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError holds all the invalid fields of a netconf
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+	return "invalid netconf: " + strings.Join(msgs, "; ")
+}
+
+// As converts the error to the CNI error of an invalid network configuration, so that the runtime gets its
+// error code
+func (e *ValidationError) As(target any) bool {
+	cniErr, ok := target.(**cniTypes.Error)
+	if ok {
+		*cniErr = cniTypes.NewError(cniTypes.ErrInvalidNetworkConfig, "invalid network configuration", e.Error())
+	}
+	return ok
+}
+
+// fieldErrors collects the field errors found while validating a netconf
+type fieldErrors []*FieldError
+
+func (errs *fieldErrors) add(field, format string, args ...any) {
+	*errs = append(*errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// splitUnknown returns the errors of the fields defined by the schema and the paths of the unknown fields
+func (errs fieldErrors) splitUnknown() (fieldErrors, []string) {
+	var known fieldErrors
+	var unknown []string
+	for _, err := range errs {
+		if err.unknown {
+			unknown = append(unknown, err.Field)
+		} else {
+			known = append(known, err)
+		}
+	}
+	return known, unknown
+}
+
+// err returns the collected errors as a ValidationError, or nil if there are none
+func (errs fieldErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: errs}
+}
+
+// ValidateConf validates the netconf like LoadConf, without reading the node files, and returns the
+// parsed netconf, if it could be parsed, and the invalid fields. Unlike LoadConf, it rejects unknown fields.
+func ValidateConf(data []byte) (*types.NetConf, []*FieldError) {
+	n, err := parseConf(data, true)
+	if err == nil {
+		return n, nil
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return n, validationErr.Fields
+	}
+	return nil, []*FieldError{{Message: err.Error()}}
+}
+
+// parseConf parses the netconf and validates it against the schema and the checks which the schema can not
+// express, reporting all the invalid fields at once. Unknown fields are only rejected if strict, otherwise
+// they are recorded in the netconf, as meta plugins may add fields of their own.
+func parseConf(data []byte, strict bool) (*types.NetConf, error) {
+	value, err := decodeJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load netconf: %v", err)
+	}
+	var schemaErrs fieldErrors
+	schemaErrs.validateSchema(netconfSchema, "", value)
+	var unknown []string
+	if !strict {
+		schemaErrs, unknown = schemaErrs.splitUnknown()
+	}
+
+	var errs fieldErrors
+	n := &types.NetConf{}
+	if err = json.Unmarshal(data, n); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("failed to load netconf: %v", err)
+		}
+		errs.add(typeErr.Field, "expected %s, got %s", typeErr.Type, typeErr.Value)
+		return nil, errs.withSchemaErrors(schemaErrs).err()
+	}
+
+	n.UnknownFields = unknown
+	errs.mergeDeviceID(n)
+	errs.validateMaster(n)
+	errs.validateIpoibSettings(n, "")
+	errs.validateMTUPolicy(n.MTUPolicy)
+	if n.IpoibAttrs != nil {
+		errs.validatePkey("ipoibAttrs.pkey", n.IpoibAttrs.Pkey)
+		errs.validateMode("ipoibAttrs.mode", n.IpoibAttrs.Mode)
+	}
+	if n.RuntimeConfig != nil && n.RuntimeConfig.Ipoib != nil {
+		o := n.RuntimeConfig.Ipoib
+		errs.validatePkey("runtimeConfig.ipoib.pkey", o.Pkey)
+		errs.validateMTU("runtimeConfig.ipoib.mtu", o.MTU)
+		errs.validateMode("runtimeConfig.ipoib.mode", o.Mode)
+	}
+	if n.MaxChildren < 0 {
+		errs.add("maxChildren", "must not be negative, got %d", n.MaxChildren)
+	}
+	if n.AutoCreateParent {
+		errs.validateAutoCreateParent(n)
+	}
+	errs.validateDefaultsFile(n.DefaultsFile)
+	errs.validateLogLevel(n.LogLevel)
+	errs.mergeIpoibAttrs(n)
+	return n, errs.withSchemaErrors(schemaErrs).err()
+}
+
+// validateMaster checks that the master is selected in exactly one way, and the selector values
+func (errs *fieldErrors) validateMaster(n *types.NetConf) {
+	var selectors []string
+	if n.Master != "" {
+		selectors = append(selectors, "master")
+	}
+	if n.MasterPci != "" {
+		selectors = append(selectors, "masterPci")
+	}
+	if n.MasterRdmaDevice != "" {
+		selectors = append(selectors, "masterRdmaDevice")
+	}
+	if n.MasterPortGUID != "" {
+		selectors = append(selectors, "masterPortGuid")
+	}
+	if len(n.Masters) > 0 {
+		selectors = append(selectors, "masters")
+	}
+
+	if len(selectors) == 0 && n.DeviceID == "" {
+		errs.add("master", "host master interface is missing")
+	}
+	if len(selectors) > 1 {
+		errs.add(selectors[1], "only one of %s may be set", strings.Join(selectors, ", "))
+	}
+
+	if n.Master != "" {
+		if err := ValidateIfName(n.Master); err != nil {
+			errs.add("master", "%v", err)
+		}
+	}
+	for i, master := range n.Masters {
+		field := fmt.Sprintf("masters[%d]", i)
+		if err := ValidateIfName(master); err != nil {
+			errs.add(field, "%v", err)
+		} else if slices.Contains(n.Masters[:i], master) {
+			errs.add(field, "%q is duplicated", master)
+		}
+	}
+	switch n.MasterSelection {
+	case "":
+	case types.MasterSelectionFailover, types.MasterSelectionLeastLoaded:
+		if len(n.Masters) == 0 {
+			errs.add("masterSelection", "can only be set with masters")
+		}
+	default:
+		errs.add("masterSelection", "must be one of %q or %q, got %q",
+			types.MasterSelectionFailover, types.MasterSelectionLeastLoaded, n.MasterSelection)
+	}
+
+	if n.MasterPort < 0 {
+		errs.add("masterPort", "must be a positive port number, got %d", n.MasterPort)
+	}
+	if n.MasterPort != 0 && n.MasterPci == "" && n.MasterRdmaDevice == "" && n.DeviceID == "" {
+		errs.add("masterPort", "can only be set with masterPci, masterRdmaDevice or deviceID")
+	}
+	if n.MasterPci != "" && !pciAddrRegexp.MatchString(n.MasterPci) {
+		errs.add("masterPci", "expected PCI address in the form 0000:03:00.0, got %q", n.MasterPci)
+	}
+	if n.DeviceID != "" && !pciAddrRegexp.MatchString(n.DeviceID) {
+		errs.add("deviceID", "expected PCI address in the form 0000:03:00.0, got %q", n.DeviceID)
+	}
+	if n.MasterPortGUID != "" && !guidRegexp.MatchString(strings.ReplaceAll(n.MasterPortGUID, ":", "")) {
+		errs.add("masterPortGuid", "expected 64 bit GUID, got %q", n.MasterPortGUID)
+	}
+}
+
+// validateIpoibSettings checks the settings of the child link which can be overridden per pod, prefix is
+// the path of the object holding them
+func (errs *fieldErrors) validateIpoibSettings(n *types.NetConf, prefix string) {
+	errs.validatePkey(prefix+"pkey", n.Pkey)
+	errs.validateMTU(prefix+"mtu", n.MTU)
+	errs.validateMode(prefix+"mode", n.Mode)
+	switch n.PkeyMembership {
+	case "", types.PkeyMembershipFull, types.PkeyMembershipLimited, types.PkeyMembershipInherit:
+	default:
+		errs.add(prefix+"pkeyMembership", "must be one of %q, %q or %q, got %q", types.PkeyMembershipFull,
+			types.PkeyMembershipLimited, types.PkeyMembershipInherit, n.PkeyMembership)
+	}
+}
+
+func (errs *fieldErrors) validatePkey(field string, pkey *types.Pkey) {
+	if pkey == nil {
+		return
+	}
+	if err := validatePkey(*pkey); err != nil {
+		errs.add(field, "%v", err)
+	}
+}
+
+func (errs *fieldErrors) validateMTU(field string, mtu int) {
+	if mtu != 0 && (mtu < minMTU || mtu > types.MaxConnectedMTU) {
+		errs.add(field, "must be in range %d-%d, got %d", minMTU, types.MaxConnectedMTU, mtu)
+	}
+}
+
+func (errs *fieldErrors) validateMTUPolicy(policy string) {
+	switch policy {
+	case "", types.MTUPolicyStrict, types.MTUPolicyClamp:
+	default:
+		errs.add("mtuPolicy", "must be one of %q or %q, got %q", types.MTUPolicyStrict, types.MTUPolicyClamp, policy)
+	}
+}
+
+func (errs *fieldErrors) validateMode(field, mode string) {
+	switch mode {
+	case "", types.IpoibModeDatagram, types.IpoibModeConnected:
+	default:
+		errs.add(field, "must be one of %q or %q, got %q", types.IpoibModeDatagram, types.IpoibModeConnected, mode)
+	}
+}
+
+// validateAutoCreateParent checks that the pkey parent interface can be created
+func (errs *fieldErrors) validateAutoCreateParent(n *types.NetConf) {
+	if n.Pkey == nil && (n.IpoibAttrs == nil || n.IpoibAttrs.Pkey == nil) && n.Partition == "" {
+		errs.add("autoCreateParent", "requires pkey")
+	}
+	if len(n.Masters) > 0 {
+		errs.add("autoCreateParent", "can not be used with masters")
+	}
+	if len(n.Master)+pkeyParentSuffixLen > types.MaxIfNameLen {
+		errs.add("master", "%q is too long to create the pkey parent interface %s.xxxx from", n.Master, n.Master)
+	}
+}
+
+// ValidateIfName checks that name is a valid Linux interface name
+func ValidateIfName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("interface name is empty")
+	case len(name) > types.MaxIfNameLen:
+		return fmt.Errorf("interface name %q is longer than %d characters", name, types.MaxIfNameLen)
+	case name == "." || name == ".." || strings.ContainsAny(name, "/: \t\n\f\r"):
+		return fmt.Errorf("interface name %q is not valid", name)
+	}
+	return nil
+}
+
+// validateDefaultsFile checks that the defaults file set in the netconf is a JSON file in the directory of the
+// node defaults file, and not one of the other node files, the netconf may be written by tenants. A symlink
+// is checked for its target.
+func (errs *fieldErrors) validateDefaultsFile(path string) {
+	if path == "" {
+		return
+	}
+	if filepath.Ext(path) != ".json" {
+		errs.add("defaultsFile", "must be a .json file, got %q", path)
+		return
+	}
+
+	dir := filepath.Dir(defaultsFile)
+	resolved, resolvedDir := filepath.Clean(path), dir
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		resolved = target
+	}
+	if target, err := filepath.EvalSymlinks(dir); err == nil {
+		resolvedDir = target
+	}
+	if filepath.Dir(filepath.Clean(path)) != dir || filepath.Dir(resolved) != resolvedDir {
+		errs.add("defaultsFile", "must be a file in %s, got %q", dir, path)
+		return
+	}
+	for _, nodeFile := range []string{policyFile, partitionsFile} {
+		if filepath.Base(resolved) == filepath.Base(nodeFile) {
+			errs.add("defaultsFile", "must not be the node file %s, got %q", nodeFile, path)
+		}
+	}
+}
+
+// validateLogLevel checks that level is one of the log levels
+func (errs *fieldErrors) validateLogLevel(level string) {
+	if err := logging.ValidateLevel(level); err != nil {
+		errs.add("logLevel", "must be one of %q, %q, %q or %q, got %q", logging.LevelDebug, logging.LevelInfo,
+			logging.LevelWarning, logging.LevelError, level)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+// jsonFields returns the JSON field names of a struct type, including the ones of embedded structs
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// schemaProperties returns the property names of the schema node
+func schemaProperties(node *schemaNode) []string {
+	names := make([]string, 0, len(node.Properties))
+	for name := range node.Properties {
+		names = append(names, name)
+	}
+	return names
+}
+
+var _ = Describe("Validation", func() {
+	fieldErrorsOf := func(conf string) []*FieldError {
+		_, _, err := LoadConf([]byte(conf))
+		var validationErr *ValidationError
+		Expect(errors.As(err, &validationErr)).To(BeTrue(), "expected validation error, got %v", err)
+		return validationErr.Fields
+	}
+
+	Context("Checking the schema", func() {
+		var root *schemaNode
+
+		BeforeEach(func() {
+			root = netconfSchema
+		})
+
+		It("describes every netconf field", func() {
+			// args is the CNI convention for passing extra arguments, not a field of the netconf
+			Expect(schemaProperties(root)).To(ConsistOf(append(jsonFields(reflect.TypeOf(types.NetConf{})), "args")))
+		})
+		It("describes every ipoibAttrs field", func() {
+			Expect(schemaProperties(root.Properties["ipoibAttrs"])).
+				To(ConsistOf(jsonFields(reflect.TypeOf(types.IpoibAttrs{}))))
+		})
+		It("describes every runtimeConfig field", func() {
+			runtimeConfig := root.Properties["runtimeConfig"]
+			Expect(schemaProperties(runtimeConfig)).To(ConsistOf(jsonFields(reflect.TypeOf(types.RuntimeConfig{}))))
+			Expect(schemaProperties(runtimeConfig.Properties["ipoib"])).
+				To(ConsistOf(jsonFields(reflect.TypeOf(types.Overrides{}))))
+		})
+	})
+
+	Context("Checking LoadConf validation", func() {
+		It("accepts ipam, capabilities and runtimeConfig of other plugins", func() {
+			_, _, err := LoadConf([]byte(`{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0",
+				"capabilities": {"ips": true}, "runtimeConfig": {"ips": ["10.0.0.1/24"]},
+				"ipam": {"type": "static", "addresses": [{"address": "10.0.0.1/24"}]},
+				"args": {"cni": {"ips": ["10.0.0.1/24"]}}}`))
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("ignores unknown fields, e.g. added by meta plugins, and records them", func() {
+			n, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "vf": 0,
+				"ipoibAttrs": {"umcasts": true}, "runtimeConfig": {"ipoib": {"mtus": 1}}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.UnknownFields).To(ConsistOf("vf", "ipoibAttrs.umcasts", "runtimeConfig.ipoib.mtus"))
+		})
+		It("rejects unknown fields on validate and suggests the misspelled one", func() {
+			_, fields := ValidateConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "vf": 0,
+				"mastrPci": "x", "ipoibAttrs": {"umcasts": true}, "runtimeConfig": {"ipoib": {"mtus": 1}}}`))
+			Expect(fields).To(ConsistOf(
+				&FieldError{Field: "ipoibAttrs.umcasts", Message: `unknown field, did you mean "umcast"?`, unknown: true},
+				&FieldError{Field: "mastrPci", Message: `unknown field, did you mean "masterPci"?`, unknown: true},
+				&FieldError{Field: "runtimeConfig.ipoib.mtus", Message: `unknown field, did you mean "mtu"?`,
+					unknown: true},
+				&FieldError{Field: "vf", Message: "unknown field", unknown: true},
+			))
+		})
+		It("reports all invalid fields at once", func() {
+			fields := fieldErrorsOf(`{"name": "mynet", "type": "ipoib", "masters": ["ib0", "ib0", "ib0/1"],
+				"mtu": 70000, "mode": "unreliable", "ipoibAttrs": {"pkey": "0x8000"}, "logLevel": "verbose"}`)
+			paths := make([]string, 0, len(fields))
+			for _, f := range fields {
+				paths = append(paths, f.Field)
+			}
+			Expect(paths).To(ConsistOf("masters[1]", "masters[2]", "mtu", "mode", "ipoibAttrs.pkey", "logLevel"))
+		})
+		It("reports the invalid network configuration CNI error code", func() {
+			_, _, err := LoadConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "mtu": 70000}`))
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(uint(cniTypes.ErrInvalidNetworkConfig)))
+			Expect(cniErr.Details).To(Equal("invalid netconf: mtu: must be in range 68-65520, got 70000"))
+		})
+		It("reports the path of a field of the wrong type", func() {
+			fields := fieldErrorsOf(`{"name": "mynet", "type": "ipoib", "master": "ib0",
+				"ipoibAttrs": {"umcast": "yes"}}`)
+			Expect(fields).To(HaveLen(1))
+			Expect(fields[0].Field).To(Equal("ipoibAttrs.umcast"))
+		})
+		DescribeTable("rejects the invalid field",
+			func(conf, field string) {
+				fields := fieldErrorsOf(`{"name": "mynet", "type": "ipoib", ` + conf + `}`)
+				Expect(fields).To(ContainElement(HaveField("Field", field)))
+			},
+			Entry("too long master", `"master": "ibp129s0f0np0.1234"`, "master"),
+			Entry("master with slash", `"master": "ib/0"`, "master"),
+			Entry("too small mtu", `"master": "ib0", "mtu": 60`, "mtu"),
+			Entry("too large mtu", `"master": "ib0", "mtu": 65521`, "mtu"),
+			Entry("too long master for the pkey parent", `"master": "ibp129s0f0np0", "pkey": "0x8001",
+				"autoCreateParent": true`, "master"),
+			Entry("invalid mtuPolicy", `"master": "ib0", "mtuPolicy": "fail"`, "mtuPolicy"),
+			Entry("invalid runtimeConfig mode", `"master": "ib0", "runtimeConfig": {"ipoib": {"mode": "rc"}}`,
+				"runtimeConfig.ipoib.mode"),
+		)
+		It("accepts autoCreateParent with the pkey of a partition", func() {
+			_, err := parseConf([]byte(`{"name": "mynet", "type": "ipoib", "master": "ib0", "partition": "storage",
+				"autoCreateParent": true}`), true)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	DescribeTable("Checking ValidateIfName function",
+		func(name string, valid bool) {
+			if valid {
+				Expect(ValidateIfName(name)).To(Succeed())
+			} else {
+				Expect(ValidateIfName(name)).NotTo(Succeed())
+			}
+		},
+		Entry("short name", "ib0", true),
+		Entry("15 characters", "ibp129s0f0.8001", true),
+		Entry("empty name", "", false),
+		Entry("16 characters", "ibp129s0f0n.8001", false),
+		Entry("dot", ".", false),
+		Entry("colon", "ib0:1", false),
+		Entry("space", "ib 0", false),
+	)
+})
@@ -0,0 +1,221 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipoib
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	"github.com/containernetworking/plugins/pkg/ns"
+
+	"github.com/Mellanox/ipoib-cni/pkg/lock"
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+const (
+	aliasPrefix      = "ipoib-cni"
+	inheritedMTUFlag = "inherited-mtu"
+	// maxAliasLen is IFALIASZ without the terminating null
+	maxAliasLen = 255
+)
+
+// tempNameRegexp matches the temporary names of the child links, given by ip.RandomVethName
+var tempNameRegexp = regexp.MustCompile(`^veth[0-9a-f]{8}$`)
+
+// childAlias is the alias set on ADD on the child links, identifying the attachment the child belongs to.
+// Its string form is "ipoib-cni:<network>:<containerID>:<ifName>[:inherited-mtu]", none of the parts
+// can hold a colon.
+type childAlias struct {
+	Network     string
+	ContainerID string
+	IfName      string
+	// InheritedMTU is set if the child inherits the MTU of its parent, see MTUReconciler
+	InheritedMTU bool
+}
+
+func (a *childAlias) String() string {
+	parts := []string{aliasPrefix, a.Network, a.ContainerID, a.IfName}
+	if a.InheritedMTU {
+		parts = append(parts, inheritedMTUFlag)
+	}
+	alias := strings.Join(parts, ":")
+	if len(alias) > maxAliasLen {
+		// too long to identify the attachment, the child is left alone by GC
+		alias = aliasPrefix
+		if a.InheritedMTU {
+			alias += ":" + inheritedMTUFlag
+		}
+	}
+	return alias
+}
+
+// parseChildAlias parses the alias of a child link, the attachment fields are empty if the alias does
+// not identify it
+func parseChildAlias(alias string) (*childAlias, bool) {
+	parts := strings.Split(alias, ":")
+	if parts[0] != aliasPrefix {
+		return nil, false
+	}
+
+	a := &childAlias{}
+	if parts[len(parts)-1] == inheritedMTUFlag {
+		a.InheritedMTU = true
+		parts = parts[:len(parts)-1]
+	}
+	switch len(parts) {
+	case 1:
+	case 4:
+		a.Network, a.ContainerID, a.IfName = parts[1], parts[2], parts[3]
+	default:
+		return nil, false
+	}
+	return a, true
+}
+
+// GarbageCollect deletes the IPoIB children of the masters of conf left in the host network namespace with a
+// temporary name, and the children of the network of conf which belong to attachments not in valid
+func (im *ipoibManager) GarbageCollect(conf *types.NetConf, valid []cniTypes.GCAttachment) error {
+	var errs []error
+	if err := im.deleteTempChildren(conf); err != nil {
+		errs = append(errs, err)
+	}
+	if err := im.doInAllNetns(func() error { return im.deleteStaleChildren(conf.Name, valid) }); err != nil {
+		errs = append(errs, err)
+	}
+	if im.store != nil {
+		if err := im.deleteStaleState(conf.Name, valid); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteStaleState deletes the state of the attachments of network not in valid, and their children in the
+// network namespace recorded on ADD, which may not be pinned under netnsDir
+func (im *ipoibManager) deleteStaleState(network string, valid []cniTypes.GCAttachment) error {
+	attachments, err := im.store.List()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, a := range attachments {
+		if a.Network != network || slices.Contains(valid, cniTypes.GCAttachment{ContainerID: a.ContainerID,
+			IfName: a.IfName}) {
+			continue
+		}
+		if netns, nsErr := ns.GetNS(a.Netns); nsErr == nil {
+			err = netns.Do(func(_ ns.NetNS) error { return im.deleteStaleChildren(network, valid) })
+			_ = netns.Close()
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if err = im.store.Delete(a.ContainerID, a.IfName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteTempChildren deletes the IPoIB children of the masters of conf in the current network namespace with
+// a temporary name, left behind by an ADD which failed or crashed before moving the child to the pod network
+// namespace
+func (im *ipoibManager) deleteTempChildren(conf *types.NetConf) error {
+	var errs []error
+	for _, master := range im.netconfMasters(conf) {
+		if err := im.deleteMasterTempChildren(master); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteMasterTempChildren deletes the children of master and of its pkey parents with a temporary name. It
+// holds the master lock, which ADD holds while its child has the temporary name.
+func (im *ipoibManager) deleteMasterTempChildren(master string) error {
+	masterLnk, ok := im.ipoibLinkByName(master)
+	if !ok {
+		return nil
+	}
+	masterLock, err := lock.Lock(im.masterLockPath(&masterLnk.LinkAttrs))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = masterLock.Unlock() }()
+
+	parents, err := im.pkeyParents(master, masterLnk.Index)
+	if err != nil {
+		return err
+	}
+	parentIndexes := map[int]bool{masterLnk.Index: true}
+	for _, index := range parents {
+		parentIndexes[index] = true
+	}
+	links, err := im.nLink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %v", err)
+	}
+
+	var errs []error
+	for _, link := range links {
+		attrs := link.Attrs()
+		if link.Type() != "ipoib" || !tempNameRegexp.MatchString(attrs.Name) || attrs.Alias != "" ||
+			!parentIndexes[attrs.ParentIndex] {
+			continue
+		}
+		if err = im.nLink.LinkDel(link); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete leaked interface %q: %v", attrs.Name, err))
+			continue
+		}
+		slog.Info("deleted leaked interface", "interface", attrs.Name, "master", master)
+	}
+	return errors.Join(errs...)
+}
+
+// deleteStaleChildren deletes the IPoIB children of network in the current network namespace which belong
+// to attachments not in valid
+func (im *ipoibManager) deleteStaleChildren(network string, valid []cniTypes.GCAttachment) error {
+	links, err := im.nLink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %v", err)
+	}
+
+	var errs []error
+	for _, link := range links {
+		alias, ok := parseChildAlias(link.Attrs().Alias)
+		if link.Type() != "ipoib" || !ok || alias.ContainerID == "" || alias.Network != network {
+			continue
+		}
+		if slices.Contains(valid, cniTypes.GCAttachment{ContainerID: alias.ContainerID, IfName: alias.IfName}) {
+			continue
+		}
+		if err = im.nLink.LinkDel(link); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete stale interface %q: %v", link.Attrs().Name, err))
+			continue
+		}
+		slog.Info("deleted stale interface", "interface", link.Attrs().Name, "containerID", alias.ContainerID,
+			"ifName", alias.IfName)
+	}
+	return errors.Join(errs...)
+}
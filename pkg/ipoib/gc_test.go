@@ -0,0 +1,159 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipoib
+
+import (
+	"errors"
+	"strings"
+
+	cniTypes "github.com/containernetworking/cni/pkg/types"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/Mellanox/ipoib-cni/pkg/state"
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+	"github.com/Mellanox/ipoib-cni/pkg/types/mocks"
+)
+
+var _ = Describe("GC", func() {
+	DescribeTable("Checking childAlias string form",
+		func(alias *childAlias, expected string, identified bool) {
+			Expect(alias.String()).To(Equal(expected))
+			parsed, ok := parseChildAlias(expected)
+			Expect(ok).To(BeTrue())
+			Expect(parsed.InheritedMTU).To(Equal(alias.InheritedMTU))
+			Expect(parsed.ContainerID != "").To(Equal(identified))
+			if identified {
+				Expect(parsed).To(Equal(alias))
+			}
+		},
+		Entry("explicit mtu", &childAlias{Network: "mynet", ContainerID: "c0ffee", IfName: "net1"},
+			"ipoib-cni:mynet:c0ffee:net1", true),
+		Entry("inherited mtu", &childAlias{Network: "mynet", ContainerID: "c0ffee", IfName: "net1", InheritedMTU: true},
+			"ipoib-cni:mynet:c0ffee:net1:inherited-mtu", true),
+		Entry("too long", &childAlias{Network: strings.Repeat("n", 250), ContainerID: "c0ffee", IfName: "net1",
+			InheritedMTU: true}, "ipoib-cni:inherited-mtu", false),
+	)
+	DescribeTable("Checking parseChildAlias function",
+		func(alias string, ok bool) {
+			_, parsed := parseChildAlias(alias)
+			Expect(parsed).To(Equal(ok))
+		},
+		Entry("empty", "", false),
+		Entry("other alias", "uplink", false),
+		Entry("prefix only", "ipoib-cni", true),
+		Entry("missing ifName", "ipoib-cni:mynet:c0ffee", false),
+	)
+
+	Context("Checking GarbageCollect function", func() {
+		var (
+			mocked *mocks.NetlinkManager
+			im     *ipoibManager
+		)
+
+		BeforeEach(func() {
+			mocked = &mocks.NetlinkManager{}
+			im = &ipoibManager{nLink: mocked, lockDir: GinkgoT().TempDir()}
+		})
+
+		ipoibLink := func(name string, index, parentIndex int, alias string) *netlink.IPoIB {
+			return &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{
+				Name: name, Index: index, ParentIndex: parentIndex, Alias: alias,
+			}}
+		}
+
+		It("Assuming children left with a temporary name on the masters of the network", func() {
+			master := ipoibLink("ib0", 2, 0, "")
+			pkeyParent := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 3, ParentIndex: 2},
+				Pkey: 0x8001}
+			otherMaster := ipoibLink("ib1", 4, 0, "")
+			leaked := ipoibLink("veth0a1b2c3d", 10, 2, "")
+			leakedOnParent := ipoibLink("veth4e5f6071", 11, 3, "")
+			other := ipoibLink("vethwithname", 12, 2, "")
+			leakedOnOtherMaster := ipoibLink("veth8a9b0c1d", 13, 4, "")
+			mocked.On("LinkByName", "ib0").Return(master, nil)
+			mocked.On("LinkList").Return([]netlink.Link{master, pkeyParent, otherMaster, leaked, leakedOnParent,
+				other, leakedOnOtherMaster}, nil)
+			mocked.On("LinkDel", leaked).Return(nil)
+			mocked.On("LinkDel", leakedOnParent).Return(nil)
+
+			Expect(im.deleteTempChildren(&types.NetConf{Master: "ib0"})).To(Succeed())
+			mocked.AssertExpectations(GinkgoT())
+			mocked.AssertNumberOfCalls(GinkgoT(), "LinkDel", 2)
+		})
+		It("Assuming children left with a temporary name on the masters of the pool", func() {
+			ib0, ib1 := ipoibLink("ib0", 2, 0, ""), ipoibLink("ib1", 4, 0, "")
+			leaked, leakedOnIb1 := ipoibLink("veth0a1b2c3d", 10, 2, ""), ipoibLink("veth8a9b0c1d", 13, 4, "")
+			mocked.On("LinkByName", "ib0").Return(ib0, nil)
+			mocked.On("LinkByName", "ib1").Return(ib1, nil)
+			mocked.On("LinkList").Return([]netlink.Link{ib0, ib1, leaked, leakedOnIb1}, nil)
+			mocked.On("LinkDel", leaked).Return(nil)
+			mocked.On("LinkDel", leakedOnIb1).Return(nil)
+
+			Expect(im.deleteTempChildren(&types.NetConf{Masters: []string{"ib0", "ib1"}})).To(Succeed())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming a missing master of the network", func() {
+			mocked.On("LinkByName", "ib0").Return(nil, errors.New("Link not found"))
+
+			Expect(im.deleteTempChildren(&types.NetConf{Master: "ib0"})).To(Succeed())
+			mocked.AssertNotCalled(GinkgoT(), "LinkList")
+		})
+		It("Assuming lock of the base interface and of its pkey children", func() {
+			master := ipoibLink("ib0", 2, 0, "")
+			pkeyParent := ipoibLink("ib0.8001", 3, 2, "")
+			Expect(im.masterLockPath(&pkeyParent.LinkAttrs)).To(Equal(im.masterLockPath(&master.LinkAttrs)))
+			Expect(im.masterLockPath(&ipoibLink("ib1", 4, 0, "").LinkAttrs)).
+				NotTo(Equal(im.masterLockPath(&master.LinkAttrs)))
+		})
+		It("Assuming children of the network not in the valid attachments", func() {
+			valid := ipoibLink("net1", 10, 2, "ipoib-cni:mynet:c0ffee:net1")
+			stale := ipoibLink("net2", 11, 2, "ipoib-cni:mynet:c0ffee:net2:inherited-mtu")
+			otherNetwork := ipoibLink("net3", 12, 2, "ipoib-cni:othernet:c0ffee:net3")
+			unknown := ipoibLink("net4", 13, 2, "ipoib-cni:inherited-mtu")
+			mocked.On("LinkList").Return([]netlink.Link{valid, stale, otherNetwork, unknown}, nil)
+			mocked.On("LinkDel", stale).Return(nil)
+
+			Expect(im.deleteStaleChildren("mynet", []cniTypes.GCAttachment{{ContainerID: "c0ffee", IfName: "net1"}})).
+				To(Succeed())
+			mocked.AssertExpectations(GinkgoT())
+			mocked.AssertNumberOfCalls(GinkgoT(), "LinkDel", 1)
+		})
+		It("Assuming state of attachments of the network not in the valid attachments", func() {
+			im.store = state.New(GinkgoT().TempDir())
+			stale := &state.Attachment{ContainerID: "c0ffee", IfName: "net1", Network: "mynet", Netns: "/nonexistent"}
+			valid := &state.Attachment{ContainerID: "c0ffee", IfName: "net2", Network: "mynet", Netns: "/nonexistent"}
+			otherNetwork := &state.Attachment{ContainerID: "c0ffee", IfName: "net3", Network: "othernet",
+				Netns: "/nonexistent"}
+			for _, a := range []*state.Attachment{stale, valid, otherNetwork} {
+				Expect(im.store.Save(a)).To(Succeed())
+			}
+
+			Expect(im.deleteStaleState("mynet", []cniTypes.GCAttachment{{ContainerID: "c0ffee", IfName: "net2"}})).
+				To(Succeed())
+			Expect(im.store.List()).To(ConsistOf(valid, otherNetwork))
+		})
+		It("Assuming failed deletion of a stale child", func() {
+			stale := ipoibLink("net2", 11, 2, "ipoib-cni:mynet:c0ffee:net2")
+			mocked.On("LinkList").Return([]netlink.Link{stale}, nil)
+			mocked.On("LinkDel", stale).Return(errors.New("failed"))
+
+			Expect(im.deleteStaleChildren("mynet", nil)).To(MatchError(ContainSubstring(`interface "net2"`)))
+		})
+	})
+})
@@ -17,23 +17,50 @@
 package ipoib
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/ip"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/containernetworking/plugins/pkg/utils/sysctl"
 	"github.com/vishvananda/netlink"
 
+	"github.com/Mellanox/ipoib-cni/pkg/lock"
+	"github.com/Mellanox/ipoib-cni/pkg/state"
+	"github.com/Mellanox/ipoib-cni/pkg/sysfs"
 	"github.com/Mellanox/ipoib-cni/pkg/types"
 )
 
 const (
 	ipV4InterfaceArpProxySysctlTemplate = "net.ipv4.conf.%s.proxy_arp"
+	// ipoibRCFlag is set in the first byte of the hardware address of IPoIB devices which support
+	// connected mode, enhanced IPoIB devices do not set it
+	ipoibRCFlag = 0x80
+	// defaultMasterPort is the RDMA device port used when the netconf selects a device without a port
+	defaultMasterPort = 1
+	// defaultNetnsDir is where container runtimes pin the network namespaces of pods
+	defaultNetnsDir = "/var/run/netns"
 )
 
 type ipoibManager struct {
 	nLink types.NetlinkManager
+	sysfs types.SysfsManager
+	// netnsDir is scanned for pod network namespaces holding IPoIB children, if empty only the
+	// current network namespace is scanned
+	netnsDir string
+	// lockDir holds the per master lock files
+	lockDir string
+	// store holds the state of the attachments, GC deletes the stale ones
+	store *state.Store
 }
 
 type netLink struct{}
@@ -73,11 +100,26 @@ func (n *netLink) LinkDel(link netlink.Link) error {
 	return netlink.LinkDel(link)
 }
 
+// LinkList using NetlinkManager
+func (n *netLink) LinkList() ([]netlink.Link, error) {
+	return netlink.LinkList()
+}
+
 // LinkSetMTU using NetlinkManager
 func (n *netLink) LinkSetMTU(link netlink.Link, mtu int) error {
 	return netlink.LinkSetMTU(link, mtu)
 }
 
+// LinkSetAlias using NetlinkManager
+func (n *netLink) LinkSetAlias(link netlink.Link, alias string) error {
+	return netlink.LinkSetAlias(link, alias)
+}
+
+// AddrList using NetlinkManager
+func (n *netLink) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	return netlink.AddrList(link, family)
+}
+
 // SetSysVal set value for sysctl attribute
 func (n *netLink) SetSysVal(attribute, value string) (string, error) {
 	return sysctl.Sysctl(attribute, value)
@@ -86,115 +128,1055 @@ func (n *netLink) SetSysVal(attribute, value string) (string, error) {
 // NewIpoibManager returns an instance of IpoibManager
 func NewIpoibManager() types.Manager {
 	return &ipoibManager{
-		nLink: &netLink{},
+		nLink:    &netLink{},
+		sysfs:    sysfs.New(sysfs.Root),
+		netnsDir: defaultNetnsDir,
+		lockDir:  lock.Dir,
+		store:    state.New(state.Dir),
 	}
 }
 
-// CreateIpoibLink create a link in pod netns
-func (im *ipoibManager) CreateIpoibLink(conf *types.NetConf, ifName string, netns ns.NetNS) (
-	*current.Interface, error,
-) {
-	iface := &current.Interface{}
-	lnk, err := im.nLink.LinkByName(conf.Master)
+// masterName returns the netdev name of the master selected in the netconf
+func (im *ipoibManager) masterName(conf *types.NetConf) (string, error) {
+	if len(conf.Masters) > 0 && conf.DeviceID == "" {
+		return im.selectMaster(conf)
+	}
+
+	master, err := im.staticMasterName(conf)
+	if err != nil || conf.DeviceID == "" {
+		return master, err
+	}
+
+	// The device allocated to the pod must agree with the master selected in the netconf
+	deviceMaster, err := im.netdevByPci(conf.DeviceID, masterPort(conf))
+	if err != nil {
+		return "", fmt.Errorf("failed to find IPoIB interface of deviceID %s: %v", conf.DeviceID, err)
+	}
+	if master != "" && master != deviceMaster {
+		return "", fmt.Errorf("deviceID %s belongs to interface %q which is not the configured master %q",
+			conf.DeviceID, deviceMaster, master)
+	}
+	if len(conf.Masters) > 0 && !slices.Contains(conf.Masters, deviceMaster) {
+		return "", fmt.Errorf("deviceID %s belongs to interface %q which is not one of the configured masters %v",
+			conf.DeviceID, deviceMaster, conf.Masters)
+	}
+	return deviceMaster, nil
+}
+
+// selectMaster picks a master from the masters pool of the netconf. Masters whose port is not active are
+// skipped, of the rest the first one is picked, or with the least-loaded selection the one with the
+// fewest IPoIB children.
+func (im *ipoibManager) selectMaster(conf *types.NetConf) (string, error) {
+	var (
+		selected         string
+		selectedChildren int
+		skipped          []string
+		policyErr        error
+		rejected         int
+	)
+	for _, master := range conf.Masters {
+		link, err := im.nLink.LinkByName(master)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", master, err))
+			continue
+		}
+		// The members not allowed by the tenant policy are never picked
+		if ipoibLnk, ok := link.(*netlink.IPoIB); ok {
+			if err = checkPolicy(conf, master, ipoibLnk); err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s: %v", master, err))
+				policyErr = err
+				rejected++
+				continue
+			}
+		}
+		if err = im.checkPortActive(master); err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", master, err))
+			continue
+		}
+		if conf.MasterSelection != types.MasterSelectionLeastLoaded {
+			return master, nil
+		}
+
+		children, err := im.masterChildren(master, link.Attrs().Index)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", master, err))
+			continue
+		}
+		if selected == "" || children < selectedChildren {
+			selected, selectedChildren = master, children
+		}
+	}
+
+	if selected == "" {
+		if rejected == len(conf.Masters) {
+			return "", policyErr
+		}
+		return "", fmt.Errorf("no usable master in %v: %s", conf.Masters, strings.Join(skipped, "; "))
+	}
+	return selected, nil
+}
+
+// checkPortActive checks that the RDMA port of an IPoIB netdev is active
+func (im *ipoibManager) checkPortActive(netdev string) error {
+	dev, port, err := im.sysfs.RdmaPortByNetdev(netdev)
+	if err != nil {
+		return err
+	}
+	state, err := im.sysfs.PortState(dev, port)
+	if err != nil {
+		return err
+	}
+	if state != sysfs.PortStateActive {
+		return fmt.Errorf("RDMA device %s port %d is %s", dev, port, state)
+	}
+	return nil
+}
+
+// checkPkeyInTable checks that the subnet manager added the partition of pkey to the pkey table of the
+// master port, otherwise the child link never gets carrier, and returns the membership of the port in the
+// partition, which is the effective membership of the child
+func (im *ipoibManager) checkPkeyInTable(master string, pkey uint16) (string, error) {
+	dev, port, membership, err := im.portMembership(master, pkey)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case membership == "":
+		return "", fmt.Errorf("pkey %s is not in the pkey table of RDMA device %s port %d of master %q, add the "+
+			"port to the partition in the subnet manager partition configuration", types.Pkey(pkey), dev, port, master)
+	case membership == types.PkeyMembershipLimited && pkey&types.PkeyFullMemberBit != 0:
+		return "", fmt.Errorf("pkey %s requests full membership, but RDMA device %s port %d of master %q is a "+
+			"limited member of the partition, add the port as a full member in the subnet manager partition "+
+			"configuration or request limited membership", types.Pkey(pkey), dev, port, master)
+	}
+	return membership, nil
+}
+
+// portMembership returns the RDMA port of master and its membership in the partition of pkey, or an empty
+// membership if the port is not a member. The kernel sets the full membership bit on every child, it sends
+// with the full member pkey only if the port pkey table holds it.
+func (im *ipoibManager) portMembership(master string, pkey uint16) (string, int, string, error) {
+	dev, port, err := im.sysfs.RdmaPortByNetdev(master)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to find the port of master %q: %v", master, err)
+	}
+	pkeys, err := im.sysfs.PortPkeys(dev, port)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	membership := ""
+	for _, portPkey := range pkeys {
+		if portPkey&types.PkeyMask != pkey&types.PkeyMask {
+			continue
+		}
+		if portPkey&types.PkeyFullMemberBit != 0 {
+			return dev, port, types.PkeyMembershipFull, nil
+		}
+		membership = types.PkeyMembershipLimited
+	}
+	return dev, port, membership, nil
+}
+
+// effectiveMembership returns the membership of the port of master in the partition of pkey, without
+// failing when it cannot be read, as for a child of the partition of the master or one already created.
+// It then falls back to the membership of masterLnk, the kernel reads the pkey of a base interface from
+// the port pkey table.
+func (im *ipoibManager) effectiveMembership(master string, masterLnk *netlink.IPoIB, pkey uint16) string {
+	_, _, membership, err := im.portMembership(master, pkey)
+	if err == nil && membership != "" {
+		return membership
+	}
+	slog.Warn("failed to read the membership of the port of the master in the partition",
+		"master", master, "pkey", types.Pkey(pkey), "error", err)
+	if masterLnk.Pkey&types.PkeyFullMemberBit != 0 {
+		return types.PkeyMembershipFull
+	}
+	return types.PkeyMembershipLimited
+}
+
+// applyPortMembership sets the membership the partition of the netconf gives to the port GUID of master, if
+// the partition lists it
+func (im *ipoibManager) applyPortMembership(conf *types.NetConf, master string) error {
+	if len(conf.PortMemberships) == 0 {
+		return nil
+	}
+	dev, port, err := im.sysfs.RdmaPortByNetdev(master)
+	if err != nil {
+		return fmt.Errorf("failed to find the port of master %q: %v", master, err)
+	}
+	guid, err := im.sysfs.PortGUID(dev, port)
+	if err != nil {
+		return err
+	}
+	if membership, ok := conf.PortMemberships[guid]; ok {
+		conf.PkeyMembership = membership
+	}
+	return nil
+}
+
+// checkMaxChildren fails if the base master already has maxChildren IPoIB children
+func (im *ipoibManager) checkMaxChildren(base string, baseLnk *netlink.IPoIB, maxChildren int) error {
+	children, err := im.masterChildren(base, baseLnk.Index)
+	if err != nil {
+		return fmt.Errorf("failed to count IPoIB children of master %q: %v", base, err)
+	}
+	if children >= maxChildren {
+		return cniTypes.NewError(cniTypes.ErrTryAgainLater, "maximum number of IPoIB children reached",
+			fmt.Sprintf("master %q has %d IPoIB children, maxChildren is %d", base, children, maxChildren))
+	}
+	return nil
+}
+
+// masterChildren returns the number of IPoIB children of the base master, counting the children of its pkey
+// parents, which are not counted themselves
+func (im *ipoibManager) masterChildren(base string, baseIndex int) (int, error) {
+	parents, err := im.pkeyParents(base, baseIndex)
+	if err != nil {
+		return 0, err
+	}
+	parentIndexes := map[int]bool{baseIndex: true}
+	for _, index := range parents {
+		parentIndexes[index] = true
+	}
+	children, err := im.countChildren(parentIndexes)
+	if err != nil {
+		return 0, err
+	}
+	// the pkey parents are children of the base master in the host network namespace
+	return children - len(parents), nil
+}
+
+// pkeyParents returns the indexes of the host pkey children of base created by autoCreateParent, i.e. named
+// after their pkey
+func (im *ipoibManager) pkeyParents(base string, baseIndex int) ([]int, error) {
+	links, err := im.nLink.LinkList()
 	if err != nil {
-		return nil, fmt.Errorf("failed to lookup master %q: %v", conf.Master, err)
+		return nil, fmt.Errorf("failed to list links: %v", err)
+	}
+
+	var parents []int
+	for _, link := range links {
+		parent, ok := link.(*netlink.IPoIB)
+		if ok && parent.ParentIndex == baseIndex && parent.Name == parentName(base, parent.Pkey) {
+			parents = append(parents, parent.Index)
+		}
+	}
+	return parents, nil
+}
+
+// countChildren returns the number of IPoIB children of the masters in parentIndexes, in the current
+// network namespace and in the network namespaces pinned under netnsDir
+func (im *ipoibManager) countChildren(parentIndexes map[int]bool) (int, error) {
+	count := 0
+	err := im.doInAllNetns(func() error {
+		nsCount, err := im.countChildrenInNs(parentIndexes)
+		count += nsCount
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// doInAllNetns runs fn in the current network namespace and in the network namespaces pinned under
+// netnsDir. Only the error of the current network namespace is returned, the pinned ones may be torn
+// down concurrently.
+func (im *ipoibManager) doInAllNetns(fn func() error) error {
+	if err := fn(); err != nil || im.netnsDir == "" {
+		return err
+	}
+
+	entries, err := os.ReadDir(im.netnsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to list network namespaces: %v", err)
+	}
+	for _, entry := range entries {
+		netns, err := ns.GetNS(filepath.Join(im.netnsDir, entry.Name()))
+		if err != nil {
+			// the namespace is being torn down
+			continue
+		}
+		if err = netns.Do(func(_ ns.NetNS) error { return fn() }); err != nil {
+			slog.Debug("failed in pinned network namespace", "netns", netns.Path(), "error", err)
+		}
+		_ = netns.Close()
+	}
+	return nil
+}
+
+// countChildrenInNs returns the number of IPoIB children of the masters in parentIndexes in the current
+// network namespace
+func (im *ipoibManager) countChildrenInNs(parentIndexes map[int]bool) (int, error) {
+	links, err := im.nLink.LinkList()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list links: %v", err)
+	}
+
+	count := 0
+	for _, link := range links {
+		if link.Type() == "ipoib" && parentIndexes[link.Attrs().ParentIndex] {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// staticMasterName returns the netdev name of the master selected by the master selectors of the netconf
+func (im *ipoibManager) staticMasterName(conf *types.NetConf) (string, error) {
+	switch {
+	case conf.MasterPci != "":
+		return im.netdevByPci(conf.MasterPci, masterPort(conf))
+	case conf.MasterRdmaDevice != "":
+		return im.sysfs.NetdevByRdmaPort(conf.MasterRdmaDevice, masterPort(conf))
+	case conf.MasterPortGUID != "":
+		dev, devPort, err := im.sysfs.RdmaPortByGUID(conf.MasterPortGUID)
+		if err != nil {
+			return "", err
+		}
+		return im.sysfs.NetdevByRdmaPort(dev, devPort)
+	}
+	return conf.Master, nil
+}
+
+// netdevByPci returns the IPoIB netdev of a port of the RDMA device of a PCI device
+func (im *ipoibManager) netdevByPci(pciAddr string, port int) (string, error) {
+	devs, err := im.sysfs.RdmaDevicesByPci(pciAddr)
+	if err != nil {
+		return "", err
+	}
+	if len(devs) != 1 {
+		return "", fmt.Errorf("expected a single RDMA device for PCI device %s, found %d", pciAddr, len(devs))
+	}
+	return im.sysfs.NetdevByRdmaPort(devs[0], port)
+}
+
+// masterPort returns the RDMA device port selected in the netconf
+func masterPort(conf *types.NetConf) int {
+	if conf.MasterPort == 0 {
+		return defaultMasterPort
+	}
+	return conf.MasterPort
+}
+
+// childPkey returns the pkey of the child link according to the netconf and the master pkey
+func childPkey(conf *types.NetConf, master *netlink.IPoIB) uint16 {
+	// partition key is 15 bits
+	pkey := master.Pkey & types.PkeyMask
+	if conf.Pkey != nil {
+		pkey = uint16(*conf.Pkey) //nolint:gosec // range is validated by config.LoadConf
+	}
+
+	switch conf.PkeyMembership {
+	case types.PkeyMembershipFull:
+		pkey |= types.PkeyFullMemberBit
+	case types.PkeyMembershipLimited:
+		pkey &= types.PkeyMask
+	case types.PkeyMembershipInherit:
+		pkey = pkey&types.PkeyMask | master.Pkey&types.PkeyFullMemberBit
+	}
+	return pkey
+}
+
+// livePkey returns the pkey the kernel set on the child, which differs from a limited member pkey as the kernel
+// forces the full membership bit, or the requested one if the link does not report it
+func livePkey(link netlink.Link, requested uint16) uint16 {
+	if ipoibLnk, ok := link.(*netlink.IPoIB); ok && ipoibLnk.Pkey != 0 {
+		return ipoibLnk.Pkey
+	}
+	return requested
+}
+
+// childMode returns the IPoIB mode of the child link according to the netconf and the master capabilities
+func childMode(conf *types.NetConf, master *netlink.IPoIB) (netlink.IPoIBMode, error) {
+	if conf.Mode == "" {
+		return master.Mode, nil
+	}
+
+	mode := netlink.StringToIPoIBMode[conf.Mode]
+	if mode == netlink.IPOIB_MODE_CONNECTED && !supportsConnectedMode(master) {
+		return mode, fmt.Errorf("%s mode is not supported by master %q, enhanced IPoIB supports %s mode only",
+			types.IpoibModeConnected, master.Name, types.IpoibModeDatagram)
+	}
+	return mode, nil
+}
+
+// mtuLimit returns the largest MTU of a child link of master in mode. Datagram mode is limited by the IB
+// MTU of the port, which the master MTU reflects when the master runs in datagram mode as well.
+func mtuLimit(mode netlink.IPoIBMode, master *netlink.IPoIB) int {
+	if mode == netlink.IPOIB_MODE_CONNECTED {
+		return types.MaxConnectedMTU
+	}
+	if master.Mode == netlink.IPOIB_MODE_DATAGRAM && master.MTU > 0 {
+		return min(master.MTU, types.MaxDatagramMTU)
+	}
+	return types.MaxDatagramMTU
+}
+
+// childMTU returns the MTU to set on the child link, 0 to keep the inherited one, checking the netconf MTU
+// against the limit of the master and mode according to the MTU policy
+func childMTU(conf *types.NetConf, mode netlink.IPoIBMode, master *netlink.IPoIB) (int, error) {
+	limit := mtuLimit(mode, master)
+	if conf.MTU <= limit {
+		return conf.MTU, nil
+	}
+	if conf.MTUPolicy == types.MTUPolicyClamp {
+		slog.Warn("clamping mtu to the limit of the master and mode", "mtu", conf.MTU, "limit", limit,
+			"master", master.Name, "mode", mode.String())
+		return limit, nil
+	}
+	return 0, fmt.Errorf("mtu %d exceeds the limit %d of %s mode on master %q (mtu %d)", conf.MTU, limit,
+		mode.String(), master.Name, master.MTU)
+}
+
+// childUmcast returns the IFLA_IPOIB_UMCAST value of the child link according to the netconf
+func childUmcast(conf *types.NetConf) uint16 {
+	if conf.Umcast != nil && !*conf.Umcast {
+		return 0
+	}
+	return 1
+}
+
+// supportsConnectedMode checks if the driver of master supports IPoIB connected mode
+func supportsConnectedMode(master *netlink.IPoIB) bool {
+	hwAddr := master.HardwareAddr
+	return len(hwAddr) > 0 && hwAddr[0]&ipoibRCFlag != 0
+}
+
+// checkPolicy checks the master, pkey and MTU the child link would be created with against the tenant
+// policy of the pod namespace
+func checkPolicy(conf *types.NetConf, master string, masterLnk *netlink.IPoIB) error {
+	policy := conf.Policy
+	if policy == nil {
+		return nil
+	}
+
+	var violations []string
+	if len(policy.Masters) > 0 && !slices.Contains(policy.Masters, master) {
+		violations = append(violations, fmt.Sprintf("master %q is not allowed", master))
+	}
+	pkey := types.Pkey(childPkey(conf, masterLnk))
+	if len(policy.Pkeys) > 0 && !slices.ContainsFunc(policy.Pkeys, func(p types.Pkey) bool {
+		return p&types.PkeyMask == pkey&types.PkeyMask
+	}) {
+		violations = append(violations, fmt.Sprintf("pkey %s is not allowed", pkey))
+	}
+	mtu := conf.MTU
+	if mtu == 0 {
+		mtu = masterLnk.MTU
+	}
+	if policy.MTU != nil && (mtu < policy.MTU.Min || (policy.MTU.Max != 0 && mtu > policy.MTU.Max)) {
+		violations = append(violations, fmt.Sprintf("mtu %d is out of the allowed range %d-%d", mtu,
+			policy.MTU.Min, policy.MTU.Max))
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return cniTypes.NewError(types.ErrPolicyViolation, "tenant policy violation",
+		fmt.Sprintf("namespace %q: %s", policy.Namespace, strings.Join(violations, ", ")))
+}
+
+// lookupMaster returns the name and the link of the master selected in the netconf
+func (im *ipoibManager) lookupMaster(conf *types.NetConf) (string, *netlink.IPoIB, error) {
+	master, err := im.masterName(conf)
+	if err != nil {
+		// keep the code of CNI errors, e.g. a policy violation
+		var cniErr *cniTypes.Error
+		if errors.As(err, &cniErr) {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("failed to find master: %v", err)
+	}
+	lnk, err := im.nLink.LinkByName(master)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to lookup master %q: %v", master, err)
 	}
 
 	if lnk.Type() != "ipoib" {
-		return nil, fmt.Errorf("master device is (%s) not of type ipoib", lnk.Type())
+		return "", nil, fmt.Errorf("master device is (%s) not of type ipoib", lnk.Type())
 	}
 
 	ipoibLnk, ok := lnk.(*netlink.IPoIB)
 	if !ok {
-		return nil, fmt.Errorf("unexpected error, failed to convert to ipoib netlink interface")
+		return "", nil, fmt.Errorf("unexpected error, failed to convert to ipoib netlink interface")
 	}
+	return master, ipoibLnk, nil
+}
 
-	// partition key is 15 bits
-	//nolint:mnd
-	pkey := ipoibLnk.Pkey & 0x7fff
-	mode := ipoibLnk.Mode
+// masterLockPath returns the lock file of the base interface of master, so that ADD and GC lock the same file
+// whether the master is the base interface or one of its pkey children
+func (im *ipoibManager) masterLockPath(master *netlink.LinkAttrs) string {
+	base := master.Index
+	if master.ParentIndex != 0 {
+		base = master.ParentIndex
+	}
+	return filepath.Join(im.lockDir, fmt.Sprintf("ifindex-%d.lock", base))
+}
+
+// ensureParent returns the name and the link of the host pkey child of base for pkey, it is created and
+// brought up if missing. Parents are never removed, as other pods may still use them.
+func (im *ipoibManager) ensureParent(base string, baseLnk *netlink.IPoIB, pkey uint16) (
+	string, *netlink.IPoIB, error,
+) {
+	// The kernel forces the full membership bit on the pkey children
+	pkey |= types.PkeyFullMemberBit
+	name := parentName(base, pkey)
+	if len(name) > types.MaxIfNameLen {
+		return "", nil, fmt.Errorf("parent interface name %q is longer than %d characters", name, types.MaxIfNameLen)
+	}
+
+	lnk, err := im.nLink.LinkByName(name)
+	if err != nil {
+		parent := &netlink.IPoIB{
+			LinkAttrs: netlink.LinkAttrs{Name: name, ParentIndex: baseLnk.Index},
+			Pkey:      pkey,
+			Mode:      baseLnk.Mode,
+			Umcast:    1,
+		}
+		if err = im.nLink.LinkAdd(parent); err != nil {
+			return "", nil, fmt.Errorf("failed to create parent interface %q: %v", name, err)
+		}
+		if lnk, err = im.nLink.LinkByName(name); err != nil {
+			return "", nil, fmt.Errorf("failed to lookup parent interface %q: %v", name, err)
+		}
+	}
+
+	parent, ok := lnk.(*netlink.IPoIB)
+	if !ok || parent.ParentIndex != baseLnk.Index || parent.Pkey != pkey {
+		return "", nil, fmt.Errorf("existing interface %q is not a pkey %s child of %q", name, types.Pkey(pkey), base)
+	}
+	if parent.Flags&net.FlagUp == 0 {
+		if err = im.nLink.LinkSetUp(parent); err != nil {
+			return "", nil, fmt.Errorf("failed to set parent interface %q up: %v", name, err)
+		}
+	}
+	return name, parent, nil
+}
+
+// parentName returns the name of the host pkey child of base for pkey, named after the full member pkey
+// the kernel sets on it whatever the requested membership
+func parentName(base string, pkey uint16) string {
+	return fmt.Sprintf("%s.%04x", base, pkey|types.PkeyFullMemberBit)
+}
+
+// podLink returns the interface named ifName in the pod netns, or nil if there is none
+func (im *ipoibManager) podLink(ifName string, netns ns.NetNS) (netlink.Link, error) {
+	var link netlink.Link
+	err := netns.Do(func(_ ns.NetNS) error {
+		var innerErr error
+		link, innerErr = im.nLink.LinkByName(ifName)
+		if isLinkNotFound(innerErr) {
+			link = nil
+			return nil
+		}
+		return innerErr
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up interface %q in netns: %v", ifName, err)
+	}
+	return link, nil
+}
+
+// ReuseIpoibLink returns the interface left in the pod by a previous ADD, or nil if the pod has no interface
+// named ifName. The interface is reused if it is an IPoIB child, with the pkey of the netconf, of the master
+// recorded by the previous ADD, of any master the netconf selects, or of their pkey parents. Any other
+// interface with the name, or one the tenant policy or the netconf no longer allow, fails ADD.
+func (im *ipoibManager) ReuseIpoibLink(conf *types.NetConf, ifName string, netns ns.NetNS, recordedMaster string) (
+	*types.IpoibLink, error,
+) {
+	link, err := im.podLink(ifName, netns)
+	if err != nil || link == nil {
+		return nil, err
+	}
+	child, ok := link.(*netlink.IPoIB)
+	if !ok {
+		return nil, cniTypes.NewError(types.ErrAlreadyExists, "interface already exists",
+			fmt.Sprintf("interface %q in the pod is not an IPoIB interface", ifName))
+	}
+
+	masters := im.netconfMasters(conf)
+	// bases maps the masters the child may be reused from to the base master the policy is checked for
+	var candidates []string
+	bases := map[string]string{}
+	for _, master := range masters {
+		masterLnk, ok := im.ipoibLinkByName(master)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, master)
+		bases[master] = master
+		if conf.AutoCreateParent {
+			parent := parentName(master, childPkey(conf, masterLnk))
+			candidates = append(candidates, parent)
+			bases[parent] = master
+		}
+	}
+	if recordedMaster != "" && !slices.Contains(candidates, recordedMaster) {
+		candidates = append(candidates, recordedMaster)
+		bases[recordedMaster] = recordedMaster
+	}
+
+	for _, master := range candidates {
+		parent, ok := im.ipoibLinkByName(master)
+		// The kernel forces the full membership bit on the children
+		if !ok || child.ParentIndex != parent.Index ||
+			child.Pkey|types.PkeyFullMemberBit != childPkey(conf, parent)|types.PkeyFullMemberBit {
+			continue
+		}
+		if err = im.checkReused(conf, child, bases[master]); err != nil {
+			return nil, err
+		}
+		slog.Info("reusing existing interface", "ifName", child.Name, "master", master)
+		return im.reusedLink(child, master, parent, netns)
+	}
+	return nil, cniTypes.NewError(types.ErrAlreadyExists, "interface already exists",
+		fmt.Sprintf("interface %q in the pod is not a child of %v with the pkey of the network", ifName, candidates))
+}
+
+// checkReused checks the reused child against the tenant policy, for its base master, and against the netconf
+func (im *ipoibManager) checkReused(conf *types.NetConf, child *netlink.IPoIB, base string) error {
+	baseLnk, ok := im.ipoibLinkByName(base)
+	if !ok {
+		return fmt.Errorf("failed to lookup master %q", base)
+	}
+	if err := checkPolicy(conf, base, baseLnk); err != nil {
+		return err
+	}
+	if err := ValidateIpoibLink(child, conf, nil); err != nil {
+		return cniTypes.NewError(types.ErrAlreadyExists, "interface already exists",
+			fmt.Sprintf("interface %q in the pod does not match the network: %v", child.Name, err))
+	}
+	return nil
+}
+
+// netconfMasters returns the masters the netconf may create children from, all the masters of the pool
+// rather than the selected one, as selecting one skips those whose port is down
+func (im *ipoibManager) netconfMasters(conf *types.NetConf) []string {
+	masters := conf.Masters
+	if len(masters) == 0 || conf.DeviceID != "" {
+		if master, err := im.masterName(conf); err == nil {
+			masters = []string{master}
+		}
+	}
+	return masters
+}
+
+// ipoibLinkByName returns the IPoIB link with the name, if it exists
+func (im *ipoibManager) ipoibLinkByName(name string) (*netlink.IPoIB, bool) {
+	link, err := im.nLink.LinkByName(name)
+	if err != nil {
+		return nil, false
+	}
+	ipoibLnk, ok := link.(*netlink.IPoIB)
+	return ipoibLnk, ok
+}
+
+// reusedLink returns the settings and the global addresses of the reused child, which ADD reports as is
+func (im *ipoibManager) reusedLink(child *netlink.IPoIB, master string, parent *netlink.IPoIB, netns ns.NetNS) (
+	*types.IpoibLink, error,
+) {
+	var ips []*current.IPConfig
+	err := netns.Do(func(_ ns.NetNS) error {
+		addrs, innerErr := im.nLink.AddrList(child, netlink.FAMILY_ALL)
+		if innerErr != nil {
+			return innerErr
+		}
+		for _, addr := range addrs {
+			if addr.Scope == int(netlink.SCOPE_LINK) {
+				continue
+			}
+			ips = append(ips, &current.IPConfig{Interface: current.Int(0), Address: *addr.IPNet})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the addresses of interface %q: %v", child.Name, err)
+	}
+	membership := im.effectiveMembership(master, parent, child.Pkey)
+
+	return &types.IpoibLink{
+		Interface: &current.Interface{
+			Name:    child.Name,
+			Mac:     child.HardwareAddr.String(),
+			Mtu:     child.MTU,
+			Sandbox: netns.Path(),
+		},
+		Pkey:           types.Pkey(child.Pkey),
+		PkeyMembership: membership,
+		Mode:           child.Mode.String(),
+		Umcast:         child.Umcast != 0,
+		MTU:            child.MTU,
+		Master:         master,
+		Index:          child.Index,
+		IPs:            ips,
+	}, nil
+}
+
+// CreateIpoibLink create a link in pod netns
+func (im *ipoibManager) CreateIpoibLink(conf *types.NetConf, containerID, ifName string, netns ns.NetNS,
+	journal types.Journal,
+) (
+	_ *types.IpoibLink, err error,
+) {
+	master, ipoibLnk, err := im.lookupMaster(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkPolicy(conf, master, ipoibLnk); err != nil {
+		return nil, err
+	}
+	if err = im.applyPortMembership(conf, master); err != nil {
+		return nil, err
+	}
+
+	// Hold the master lock until the child is moved to the pod, so that concurrent invocations count it
+	// and do not race creating the parent, and GC does not delete it while it has the temporary name
+	masterLock, err := lock.Lock(im.masterLockPath(&ipoibLnk.LinkAttrs))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = masterLock.Unlock() }()
+
+	// ReuseIpoibLink reuses the child of a previous ADD, one showing up now belongs to a concurrent ADD
+	existing, err := im.podLink(ifName, netns)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, cniTypes.NewError(types.ErrAlreadyExists, "interface already exists",
+			fmt.Sprintf("interface %q was created in the pod by a concurrent ADD", ifName))
+	}
+
+	if conf.MaxChildren > 0 {
+		if err = im.checkMaxChildren(master, ipoibLnk, conf.MaxChildren); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.AutoCreateParent {
+		master, ipoibLnk, err = im.ensureParent(master, ipoibLnk, childPkey(conf, ipoibLnk))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pkey := childPkey(conf, ipoibLnk)
+	mode, err := childMode(conf, ipoibLnk)
+	if err != nil {
+		return nil, err
+	}
+	mtu, err := childMTU(conf, mode, ipoibLnk)
+	if err != nil {
+		return nil, err
+	}
+
+	// A child of the partition of the master needs no check, the port is a member of it
+	membership := ""
+	if conf.Pkey != nil {
+		if membership, err = im.checkPkeyInTable(master, pkey); err != nil {
+			return nil, err
+		}
+	} else {
+		membership = im.effectiveMembership(master, ipoibLnk, pkey)
+	}
 
 	tmpName, err := ip.RandomVethName()
 	if err != nil {
 		return nil, err
 	}
+	if journal != nil {
+		if err = journal.RecordLink(tmpName); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every completed step registers its inverse, so that a failure reverts the host and the pod to their
+	// state before ADD
+	var undo undoStack
+	defer func() {
+		if err == nil {
+			return
+		}
+		if undoErr := undo.unwind(); undoErr != nil {
+			slog.Warn("failed to revert interface creation", "tempName", tmpName, "error", undoErr)
+		}
+	}()
+
+	umcast := childUmcast(conf)
+	if err = im.addChild(tmpName, ipoibLnk.Index, pkey, mode, umcast, netns, &undo); err != nil {
+		return nil, err
+	}
+	alias := &childAlias{Network: conf.Name, ContainerID: containerID, IfName: ifName, InheritedMTU: mtu == 0}
+	contLink, err := im.configureChild(tmpName, ifName, mtu, alias, netns, journal, &undo)
+	if err != nil {
+		return nil, err
+	}
 
+	return &types.IpoibLink{
+		Interface: &current.Interface{
+			Name:    ifName,
+			Mac:     contLink.Attrs().HardwareAddr.String(),
+			Mtu:     contLink.Attrs().MTU,
+			Sandbox: netns.Path(),
+		},
+		Pkey:           types.Pkey(livePkey(contLink, pkey)),
+		PkeyMembership: membership,
+		Mode:           mode.String(),
+		Umcast:         umcast != 0,
+		MTU:            contLink.Attrs().MTU,
+		Master:         master,
+		Index:          contLink.Attrs().Index,
+		TempName:       tmpName,
+	}, nil
+}
+
+// addChild creates the child of the parent with the temporary name and moves it to the pod network namespace
+func (im *ipoibManager) addChild(tmpName string, parentIndex int, pkey uint16, mode netlink.IPoIBMode,
+	umcast uint16, netns ns.NetNS, undo *undoStack,
+) error {
 	ipoibLink := &netlink.IPoIB{
 		LinkAttrs: netlink.LinkAttrs{
 			Name:        tmpName,
-			ParentIndex: lnk.Attrs().Index,
+			ParentIndex: parentIndex,
 			// Due to kernal bug create the link then move it to the desired namespace
 			//		Namespace:   netlink.NsFd(int(curNetns.Fd())),
 		},
 		Pkey:   pkey,
 		Mode:   mode,
-		Umcast: 1,
+		Umcast: umcast,
 	}
 
-	if err = im.nLink.LinkAdd(ipoibLink); err != nil {
-		return nil, fmt.Errorf("failed to create interface: %v", err)
+	if err := im.nLink.LinkAdd(ipoibLink); err != nil {
+		return fmt.Errorf("failed to create interface: %v", err)
 	}
+	// The index is only known once the link exists, so the inverse looks it up
+	undo.push(func() error {
+		link, err := im.nLink.LinkByName(tmpName)
+		if err != nil {
+			return err
+		}
+		return im.nLink.LinkDel(link)
+	})
 	link, err := im.nLink.LinkByName(tmpName)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	// Do not silently fall back if the driver did not honor the requested mode
+	if created, ok := link.(*netlink.IPoIB); ok && created.Mode != mode {
+		return fmt.Errorf("failed to create interface in %s mode, driver set it to %s mode",
+			mode.String(), created.Mode.String())
 	}
 
 	fd := int(netns.Fd()) //nolint:gosec // fd values fit in int
 	if err = im.nLink.LinkSetNsFd(link, fd); err != nil {
-		return nil, fmt.Errorf("failed to move interface %s to netns: %v", tmpName, err)
+		return fmt.Errorf("failed to move interface %s to netns: %v", tmpName, err)
 	}
+	// The undo runs in the host netns, it is only opened when the move has to be reverted
+	undo.push(func() error {
+		hostNetns, err := ns.GetCurrentNS()
+		if err != nil {
+			return fmt.Errorf("failed to open the host netns: %v", err)
+		}
+		defer func() { _ = hostNetns.Close() }()
+		return netns.Do(func(_ ns.NetNS) error {
+			podLink, innerErr := im.nLink.LinkByName(tmpName)
+			if innerErr != nil {
+				return innerErr
+			}
+			return im.nLink.LinkSetNsFd(podLink, int(hostNetns.Fd())) //nolint:gosec // fd values fit in int
+		})
+	})
+	return nil
+}
 
-	err = netns.Do(func(_ ns.NetNS) error {
+// configureChild renames the child moved to the pod network namespace, sets its MTU and alias and brings it up.
+// It returns the child refetched once configured.
+func (im *ipoibManager) configureChild(tmpName, ifName string, mtu int, alias *childAlias, netns ns.NetNS,
+	journal types.Journal, undo *undoStack,
+) (netlink.Link, error) {
+	var contLink netlink.Link
+	err := netns.Do(func(_ ns.NetNS) error {
+		// The link object of the host netns does not hold the index of the link in the pod netns
+		link, innerErr := im.nLink.LinkByName(tmpName)
+		if innerErr != nil {
+			return fmt.Errorf("failed to find interface %q in netns: %v", tmpName, innerErr)
+		}
+		// Once renamed, the rollback of an interrupted ADD tells the child from the one of another ADD by its index
+		if journal != nil {
+			if innerErr = journal.RecordIndex(link.Attrs().Index); innerErr != nil {
+				return innerErr
+			}
+		}
+
+		// proxy_arp, the MTU and the alias go away with the link, so they need no inverse
 		ipv4SysctlValueName := fmt.Sprintf(ipV4InterfaceArpProxySysctlTemplate, tmpName)
-		if _, innerErr := im.nLink.SetSysVal(ipv4SysctlValueName, "1"); innerErr != nil {
-			// remove the newly added link and ignore errors, because we already are in a failed state
-			_ = im.nLink.LinkDel(ipoibLink)
+		if _, innerErr = im.nLink.SetSysVal(ipv4SysctlValueName, "1"); innerErr != nil {
 			return fmt.Errorf("failed to set proxy_arp on newly added interface %q: %v", tmpName, innerErr)
 		}
 
-		if innerErr := im.nLink.LinkSetDown(link); innerErr != nil {
-			return innerErr
+		if innerErr = im.nLink.LinkSetDown(link); innerErr != nil {
+			return fmt.Errorf("failed to set interface %q down: %v", tmpName, innerErr)
 		}
-		if innerErr := im.nLink.LinkSetName(link, ifName); innerErr != nil {
-			_ = im.nLink.LinkDel(ipoibLink)
+		if innerErr = im.nLink.LinkSetName(link, ifName); innerErr != nil {
 			return fmt.Errorf("failed to rename interface to %q: %v", ifName, innerErr)
 		}
-		if conf.MTU > 0 {
-			if innerErr := im.nLink.LinkSetMTU(link, conf.MTU); innerErr != nil {
-				_ = im.nLink.LinkDel(ipoibLink)
-				return fmt.Errorf("failed to set MTU %d on interface %q: %v", conf.MTU, ifName, innerErr)
+		// The name in the pod may be taken in the host netns
+		undo.push(func() error {
+			return netns.Do(func(_ ns.NetNS) error { return im.nLink.LinkSetName(link, tmpName) })
+		})
+		if mtu > 0 {
+			if innerErr = im.nLink.LinkSetMTU(link, mtu); innerErr != nil {
+				return fmt.Errorf("failed to set MTU %d on interface %q: %v", mtu, ifName, innerErr)
 			}
 		}
-		if innerErr := im.nLink.LinkSetUp(link); innerErr != nil {
-			return innerErr
+		// GC and the MTU reconciler find the children by their alias
+		if innerErr = im.nLink.LinkSetAlias(link, alias.String()); innerErr != nil {
+			return fmt.Errorf("failed to set alias on interface %q: %v", ifName, innerErr)
 		}
-		iface.Name = ifName
+		if innerErr = im.nLink.LinkSetUp(link); innerErr != nil {
+			return fmt.Errorf("failed to set interface %q up: %v", ifName, innerErr)
+		}
+		// A link is renamed only when it is down
+		undo.push(func() error {
+			return netns.Do(func(_ ns.NetNS) error { return im.nLink.LinkSetDown(link) })
+		})
 
-		ipoibContLink, innerErr := im.nLink.LinkByName(ifName)
-		if innerErr != nil {
+		if contLink, innerErr = im.nLink.LinkByName(ifName); innerErr != nil {
 			return fmt.Errorf("failed to refetch interface %q: %v", ifName, innerErr)
 		}
-		iface.Mac = ipoibContLink.Attrs().HardwareAddr.String()
-		iface.Sandbox = netns.Path()
-
 		return nil
 	})
+	return contLink, err
+}
+
+// CheckMaster checks that the master selected by the netconf exists, is of type ipoib and that its
+// InfiniBand port is active. A master chosen per pod by the runtime deviceID is not known before ADD,
+// without a master set in the netconf there is nothing to check.
+func (im *ipoibManager) CheckMaster(conf *types.NetConf) error {
+	if conf.DeviceID == "" && conf.Master == "" && conf.MasterPci == "" && conf.MasterRdmaDevice == "" &&
+		conf.MasterPortGUID == "" && len(conf.Masters) == 0 {
+		return nil
+	}
+	master, _, err := im.lookupMaster(conf)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err = im.checkPortActive(master); err != nil {
+		return fmt.Errorf("master %q is not ready: %v", master, err)
+	}
+	return nil
+}
+
+// ValidateIpoibLink checks that the IPoIB attributes of link match the ones set in the netconf. The pkey is
+// compared against the one read back on ADD, recorded in the attachment state, as the kernel may set the
+// full membership bit.
+func ValidateIpoibLink(link netlink.Link, conf *types.NetConf, a *state.Attachment) error {
+	ipoibLnk, ok := link.(*netlink.IPoIB)
+	if !ok {
+		return fmt.Errorf("interface %s not of type ipoib", link.Attrs().Name)
 	}
 
-	return iface, nil
+	if conf.Pkey != nil || conf.PkeyMembership != "" {
+		// The live pkey stands in for the master pkey, so only the parts set in the netconf are compared
+		expected := childPkey(conf, ipoibLnk) | types.PkeyFullMemberBit
+		if a != nil {
+			expected = uint16(a.Pkey) //nolint:gosec // read back from the link on ADD
+		}
+		if ipoibLnk.Pkey != expected {
+			return fmt.Errorf("interface %s pkey %s does not match expected pkey %s",
+				ipoibLnk.Name, types.Pkey(ipoibLnk.Pkey), types.Pkey(expected))
+		}
+	}
+	if conf.Mode != "" && netlink.StringToIPoIBMode[conf.Mode] != ipoibLnk.Mode {
+		return fmt.Errorf("interface %s mode %s does not match expected mode %s",
+			ipoibLnk.Name, ipoibLnk.Mode.String(), conf.Mode)
+	}
+	if conf.Umcast != nil && ipoibLnk.Umcast != childUmcast(conf) {
+		return fmt.Errorf("interface %s umcast %d does not match expected umcast %d",
+			ipoibLnk.Name, ipoibLnk.Umcast, childUmcast(conf))
+	}
+	// A clamped MTU is below the netconf one
+	if conf.MTU > 0 && ipoibLnk.MTU != conf.MTU && (conf.MTUPolicy != types.MTUPolicyClamp || ipoibLnk.MTU > conf.MTU) {
+		return fmt.Errorf("interface %s mtu %d does not match expected mtu %d", ipoibLnk.Name, ipoibLnk.MTU, conf.MTU)
+	}
+	return nil
 }
 
 func (im *ipoibManager) RemoveIpoibLink(ifName string, netns ns.NetNS) error {
+	return im.RemoveIpoibLinkWithIndex(ifName, 0, netns)
+}
+
+// RemoveIpoibLinkWithIndex deletes the interface named ifName in the pod netns if it has the index, or
+// whatever its index if index is 0
+func (im *ipoibManager) RemoveIpoibLinkWithIndex(ifName string, index int, netns ns.NetNS) error {
 	// There is a netns so try to clean up. Delete can be called multiple times
 	// so don't return an error if the device is already removed.
 	return netns.Do(func(_ ns.NetNS) error {
 		link, err := im.nLink.LinkByName(ifName)
 		if err != nil {
-			return nil //nolint:nilerr // link not present in container, nothing to delete
+			if isLinkNotFound(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to look up interface %q: %v", ifName, err)
 		}
-
-		return im.nLink.LinkDel(link)
+		if index != 0 && link.Attrs().Index != index {
+			slog.Info("keeping interface of another ADD", "ifName", ifName, "index", link.Attrs().Index,
+				"expectedIndex", index)
+			return nil
+		}
+		if err = im.nLink.LinkDel(link); err != nil && !isLinkNotFound(err) {
+			return fmt.Errorf("failed to delete interface %q: %v", ifName, err)
+		}
+		return nil
 	})
 }
+
+// RemoveHostLink deletes the child link left in the host network namespace with its temporary name, if any
+func (im *ipoibManager) RemoveHostLink(tempName string) error {
+	// a reused child has no temporary name
+	if tempName == "" {
+		return nil
+	}
+	link, err := im.nLink.LinkByName(tempName)
+	if err != nil {
+		if isLinkNotFound(err) {
+			// the link was moved to the pod network namespace
+			return nil
+		}
+		return fmt.Errorf("failed to look up interface %q: %v", tempName, err)
+	}
+	// the children get their alias in the pod network namespace, a link with an alias is not ours
+	if link.Type() != "ipoib" || link.Attrs().Alias != "" {
+		return nil
+	}
+	if err = im.nLink.LinkDel(link); err != nil && !isLinkNotFound(err) {
+		return fmt.Errorf("failed to delete interface %q: %v", tempName, err)
+	}
+	return nil
+}
+
+// isLinkNotFound returns true if err reports a missing link, on lookup or on a link deleted meanwhile
+func isLinkNotFound(err error) bool {
+	var notFound netlink.LinkNotFoundError
+	return errors.As(err, &notFound) || errors.Is(err, syscall.ENODEV)
+}
+
+// ValidateAttachment checks that link is the child link created on ADD for the attachment
+func ValidateAttachment(link netlink.Link, a *state.Attachment) error {
+	if link.Attrs().Index != a.IfIndex {
+		return fmt.Errorf("interface %s index %d does not match index %d created on ADD",
+			link.Attrs().Name, link.Attrs().Index, a.IfIndex)
+	}
+	if ipoibLnk, ok := link.(*netlink.IPoIB); ok && types.Pkey(ipoibLnk.Pkey) != a.Pkey {
+		return fmt.Errorf("interface %s pkey %s does not match pkey %s created on ADD",
+			link.Attrs().Name, types.Pkey(ipoibLnk.Pkey), a.Pkey)
+	}
+	return nil
+}
@@ -18,13 +18,17 @@ package ipoib
 
 import (
 	"errors"
+	"net"
+	"syscall"
 
+	cniTypes "github.com/containernetworking/cni/pkg/types"
 	"github.com/containernetworking/plugins/pkg/ns"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
 	"github.com/vishvananda/netlink"
 
+	"github.com/Mellanox/ipoib-cni/pkg/state"
 	"github.com/Mellanox/ipoib-cni/pkg/types"
 	"github.com/Mellanox/ipoib-cni/pkg/types/mocks"
 )
@@ -70,6 +74,23 @@ func (f *fakeNetNS) Close() error {
 	return nil
 }
 
+// fakeJournal records the temporary names and the indexes of the links
+type fakeJournal struct {
+	tempNames []string
+	indexes   []int
+	err       error
+}
+
+func (j *fakeJournal) RecordLink(tempName string) error {
+	j.tempNames = append(j.tempNames, tempName)
+	return j.err
+}
+
+func (j *fakeJournal) RecordIndex(index int) error {
+	j.indexes = append(j.indexes, index)
+	return nil
+}
+
 func newFakeNs() ns.NetNS {
 	return &fakeNetNS{
 		closed: false,
@@ -78,21 +99,36 @@ func newFakeNs() ns.NetNS {
 	}
 }
 
+func pkeyPtr(pkey int) *types.Pkey {
+	p := types.Pkey(pkey)
+	return &p
+}
+
 var _ = Describe("IPoIB", func() {
 
 	Context("Checking CreateIpoibLink function", func() {
+		const containerID = "c0ffee"
+
 		var (
 			ifName         string
 			netconf        *types.NetConf
 			fakeMasterLink *netlink.IPoIB
+			mockedSysfs    *mocks.SysfsManager
 		)
 
 		BeforeEach(func() {
 			ifName = "eth0"
 			netconf = &types.NetConf{
-				Master: "ib0",
+				NetConf: cniTypes.NetConf{Name: "mynet"},
+				Master:  "ib0",
 			}
 			fakeMasterLink = &netlink.IPoIB{LinkAttrs: netlink.NewLinkAttrs(), Pkey: 0xffff, Mode: netlink.IPOIB_MODE_DATAGRAM}
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("mlx5_0", 1, nil).Maybe()
+			mockedSysfs.On("PortPkeys", "mlx5_0", 1).Return([]uint16{0xffff, 0x8001}, nil).Maybe()
+			mockedSysfs.On("RdmaPortByNetdev", "ib0.8001").Return("mlx5_0", 1, nil).Maybe()
+			mockedSysfs.On("RdmaPortByNetdev", "ib1").Return("mlx5_1", 1, nil).Maybe()
+			mockedSysfs.On("PortPkeys", "mlx5_1", 1).Return([]uint16{0xffff}, nil).Maybe()
 		})
 
 		It("Assuming create link and move it to container", func() {
@@ -101,6 +137,7 @@ var _ = Describe("IPoIB", func() {
 			fakeLink := &FakeLink{}
 
 			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
 			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
 				return l.Pkey == (fakeMasterLink.Pkey&0x7fff) && l.Mode == fakeMasterLink.Mode
 			})).Return(nil)
@@ -109,22 +146,423 @@ var _ = Describe("IPoIB", func() {
 			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
 			mocked.On("LinkSetDown", fakeLink).Return(nil)
 			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
 			mocked.On("LinkSetUp", fakeLink).Return(nil)
 
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			journal := &fakeJournal{}
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			fakeLink.Index = 9
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, journal)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink).NotTo(BeNil())
+			Expect(ipoibLink.Master).To(Equal("ib0"))
+			Expect(ipoibLink.TempName).To(MatchRegexp("^veth[0-9a-f]{8}$"))
+			Expect(journal.tempNames).To(Equal([]string{ipoibLink.TempName}))
+			Expect(journal.indexes).To(Equal([]int{9}))
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming pkey and membership read back from the created link", func() {
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+			// The kernel sets the full membership bit on the child
+			contLink := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName}, Pkey: 0xffff}
+
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once()
+			mocked.On("LinkByName", ifName).Return(contLink, nil)
+			mocked.On("LinkAdd", mock.Anything).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, newFakeNs(), nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Pkey).To(Equal(types.Pkey(0xffff)))
+			Expect(ipoibLink.PkeyMembership).To(Equal(types.PkeyMembershipFull))
+		})
+		It("Assuming limited member port with the full membership bit forced by the kernel", func() {
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+			netconf.Pkey = pkeyPtr(0x0002)
+			contLink := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName}, Pkey: 0x8002}
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("mlx5_0", 1, nil)
+			mockedSysfs.On("PortPkeys", "mlx5_0", 1).Return([]uint16{0xffff, 0x0002}, nil)
+
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once()
+			mocked.On("LinkByName", ifName).Return(contLink, nil)
+			mocked.On("LinkAdd", mock.Anything).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, newFakeNs(), nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Pkey).To(Equal(types.Pkey(0x8002)))
+			Expect(ipoibLink.PkeyMembership).To(Equal(types.PkeyMembershipLimited))
+		})
+		It("Assuming failed to record the link in the journal", func() {
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, newFakeNs(),
+				&fakeJournal{err: errors.New("failed to write journal")})
+
+			Expect(err).To(HaveOccurred())
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
+		It("Assuming master not allowed by the tenant policy", func() {
+			mocked := &mocks.NetlinkManager{}
+			netconf.Policy = &types.TenantPolicy{Namespace: "tenant-a", Masters: []string{"ib1"}}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			_, err := im.CreateIpoibLink(netconf, containerID, ifName, newFakeNs(), nil)
+
+			Expect(err).To(MatchError(ContainSubstring(`namespace "tenant-a": master "ib0" is not allowed`)))
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
+		It("Assuming create link with explicit pkey", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			pkey := types.Pkey(0x8001)
+			netconf.Pkey = &pkey
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
+				return l.Pkey == 0x8001 && l.ParentIndex == fakeMasterLink.Index
+			})).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(ipoibLink).NotTo(BeNil())
+			Expect(ipoibLink.Pkey).To(Equal(types.Pkey(0x8001)))
+			Expect(ipoibLink.PkeyMembership).To(Equal(types.PkeyMembershipFull))
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming create link in connected mode", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			netconf.Mode = types.IpoibModeConnected
+			fakeMasterLink.HardwareAddr = net.HardwareAddr{0x80, 0x00, 0x02, 0x08}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
+				return l.Mode == netlink.IPOIB_MODE_CONNECTED
+			})).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Mode).To(Equal(types.IpoibModeConnected))
 			mocked.AssertExpectations(GinkgoT())
 		})
+		It("Assuming connected mode on enhanced IPoIB master", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			netconf.Mode = types.IpoibModeConnected
+			fakeMasterLink.HardwareAddr = net.HardwareAddr{0x00, 0x00, 0x02, 0x08}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming driver did not honor requested mode", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			createdLink := &netlink.IPoIB{Mode: netlink.IPOIB_MODE_DATAGRAM}
+
+			netconf.Mode = types.IpoibModeConnected
+			fakeMasterLink.HardwareAddr = net.HardwareAddr{0x80, 0x00, 0x02, 0x08}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.Anything).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(createdLink, nil)
+			mocked.On("LinkDel", createdLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming create link with umcast disabled", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			umcast := false
+			netconf.Umcast = &umcast
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
+				return l.Umcast == 0
+			})).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Umcast).To(BeFalse())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming create link below maxChildren", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			netconf.MaxChildren = 2
+			fakeMasterLink.Index = 4
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkList").Return([]netlink.Link{
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 10, ParentIndex: 4}},
+			}, nil)
+			mocked.On("LinkAdd", mock.Anything).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink).NotTo(BeNil())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming maxChildren reached", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			netconf.MaxChildren = 2
+			fakeMasterLink.Index = 4
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkList").Return([]netlink.Link{
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 10, ParentIndex: 4}},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 11, ParentIndex: 4}},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 12, ParentIndex: 5}},
+			}, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(HaveOccurred())
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(cniTypes.ErrTryAgainLater))
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming maxChildren reached with autoCreateParent across the pkey parents", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			netconf.MaxChildren = 2
+			netconf.Pkey = pkeyPtr(0x8001)
+			netconf.AutoCreateParent = true
+			fakeMasterLink.Index = 4
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkList").Return([]netlink.Link{
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 7, ParentIndex: 4}, Pkey: 0x8001},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib0.8002", Index: 8, ParentIndex: 4}, Pkey: 0x8002},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 10, ParentIndex: 7}},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 11, ParentIndex: 8}},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 12, ParentIndex: 5}},
+			}, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(HaveOccurred())
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(cniTypes.ErrTryAgainLater))
+			Expect(cniErr.Details).To(ContainSubstring("master \"ib0\" has 2 IPoIB children"))
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+			mocked.AssertNotCalled(GinkgoT(), "LinkByName", "ib0.8001")
+		})
+		It("Assuming partition listing the port GUID of the master", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			netconf.Pkey = pkeyPtr(0x0001)
+			netconf.PkeyMembership = types.PkeyMembershipFull
+			netconf.PortMemberships = map[string]string{"0c42a10300175a3d": types.PkeyMembershipLimited}
+			mockedSysfs.On("PortGUID", "mlx5_0", 1).Return("0c42a10300175a3d", nil)
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool { return l.Pkey == 0x0001 })).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			_, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(netconf.PkeyMembership).To(Equal(types.PkeyMembershipLimited))
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming partition not listing the port GUID of the master", func() {
+			netconf.PkeyMembership = types.PkeyMembershipFull
+			netconf.PortMemberships = map[string]string{"0c42a10300175a3d": types.PkeyMembershipLimited}
+			mockedSysfs.On("PortGUID", "mlx5_0", 1).Return("0c42a10300175a3c", nil)
+
+			im := ipoibManager{sysfs: mockedSysfs}
+			Expect(im.applyPortMembership(netconf, "ib0")).To(Succeed())
+			Expect(netconf.PkeyMembership).To(Equal(types.PkeyMembershipFull))
+		})
+		It("Assuming pkey missing in the port pkey table", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			netconf.Pkey = pkeyPtr(0x8002)
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(MatchError(ContainSubstring("not in the pkey table")))
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming full membership requested for a limited member port", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			netconf.Pkey = pkeyPtr(0x8003)
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("mlx5_0", 1, nil)
+			mockedSysfs.On("PortPkeys", "mlx5_0", 1).Return([]uint16{0xffff, 0x0003}, nil)
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(MatchError(ContainSubstring("limited member")))
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
+		It("Assuming pkey table of the master port can not be read", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			netconf.Pkey = pkeyPtr(0x8001)
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("", 0, errors.New("not found"))
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
+		It("Assuming pkey table of the master port can not be read without pkey", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("", 0, errors.New("not found"))
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.Anything).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			// the child inherits the partition of the master, the table is not checked
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.PkeyMembership).To(Equal(types.PkeyMembershipFull))
+			mockedSysfs.AssertNotCalled(GinkgoT(), "PortPkeys", mock.Anything, mock.Anything)
+		})
 		It("Assuming not existing master", func() {
 			targetNetNS := newFakeNs()
 			mocked := &mocks.NetlinkManager{}
 
 			mocked.On("LinkByName", netconf.Master).Return(nil, errors.New("not found"))
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 
 			Expect(err).To(HaveOccurred())
 			Expect(ipoibLink).To(BeNil())
@@ -135,9 +573,10 @@ var _ = Describe("IPoIB", func() {
 			mocked := &mocks.NetlinkManager{}
 
 			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
 			mocked.On("LinkAdd", mock.Anything).Return(errors.New("failed"))
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(ipoibLink).To(BeNil())
 
@@ -149,14 +588,15 @@ var _ = Describe("IPoIB", func() {
 			fakeLink := &FakeLink{}
 
 			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
 			mocked.On("LinkAdd", mock.Anything).Return(nil)
 			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
 			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
 			mocked.On("LinkDel", mock.Anything).Return(nil)
 			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", errors.New("failed"))
 
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 
 			Expect(err).To(HaveOccurred())
 			Expect(ipoibLink).To(BeNil())
@@ -169,6 +609,7 @@ var _ = Describe("IPoIB", func() {
 
 			netconf.MTU = 1496
 			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
 			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
 				return l.Pkey == (fakeMasterLink.Pkey&0x7fff) && l.Mode == fakeMasterLink.Mode
 			})).Return(nil)
@@ -178,15 +619,60 @@ var _ = Describe("IPoIB", func() {
 			mocked.On("LinkSetDown", fakeLink).Return(nil)
 			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
 			mocked.On("LinkSetMTU", fakeLink, 1496).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, "ipoib-cni:mynet:c0ffee:eth0").Return(nil)
 			mocked.On("LinkSetUp", fakeLink).Return(nil)
 
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 
 			Expect(err).NotTo(HaveOccurred())
 			Expect(ipoibLink).NotTo(BeNil())
 			mocked.AssertExpectations(GinkgoT())
 		})
+		It("Assuming MTU above the master limit is clamped", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+			fakeLink := &FakeLink{}
+
+			fakeMasterLink.MTU = 2044
+			netconf.MTU = 4092
+			netconf.MTUPolicy = types.MTUPolicyClamp
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+			mocked.On("LinkAdd", mock.Anything).Return(nil)
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
+			mocked.On("SetSysVal", mock.AnythingOfType("string"), mock.AnythingOfType("string")).Return("", nil)
+			mocked.On("LinkSetDown", fakeLink).Return(nil)
+			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetMTU", fakeLink, 2044).Return(nil)
+			mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(nil)
+			mocked.On("LinkSetUp", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink).NotTo(BeNil())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming MTU above the master limit is rejected before creating the link", func() {
+			targetNetNS := newFakeNs()
+			mocked := &mocks.NetlinkManager{}
+
+			fakeMasterLink.MTU = 2044
+			netconf.MTU = 4092
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
+
+			Expect(err).To(MatchError(ContainSubstring("mtu 4092 exceeds the limit 2044")))
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertExpectations(GinkgoT())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
 		It("Assuming failed to set MTU", func() {
 			targetNetNS := newFakeNs()
 			mocked := &mocks.NetlinkManager{}
@@ -194,6 +680,7 @@ var _ = Describe("IPoIB", func() {
 
 			netconf.MTU = 1496
 			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
 			mocked.On("LinkAdd", mock.Anything).Return(nil)
 			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
 			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
@@ -203,8 +690,8 @@ var _ = Describe("IPoIB", func() {
 			mocked.On("LinkSetMTU", fakeLink, 1496).Return(errors.New("failed to set MTU"))
 			mocked.On("LinkDel", mock.Anything).Return(nil)
 
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 
 			Expect(err).To(HaveOccurred())
 			Expect(ipoibLink).To(BeNil())
@@ -216,6 +703,7 @@ var _ = Describe("IPoIB", func() {
 			fakeLink := &FakeLink{}
 
 			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
 			mocked.On("LinkAdd", mock.Anything).Return(nil)
 			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
 			mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(nil)
@@ -224,12 +712,667 @@ var _ = Describe("IPoIB", func() {
 			mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(errors.New("failed"))
 			mocked.On("LinkDel", mock.Anything).Return(nil)
 
-			im := ipoibManager{nLink: mocked}
-			ipoibLink, err := im.CreateIpoibLink(netconf, ifName, targetNetNS)
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, targetNetNS, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(ipoibLink).To(BeNil())
 			mocked.AssertExpectations(GinkgoT())
 		})
+		It("Assuming interface created in the pod meanwhile", func() {
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName}}, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+			ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, newFakeNs(), nil)
+
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrAlreadyExists))
+			Expect(ipoibLink).To(BeNil())
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
+		It("Assuming no interface of a previous ADD in the pod", func() {
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{})
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			Expect(im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")).To(BeNil())
+		})
+		It("Assuming interface of a previous ADD in the pod", func() {
+			mocked := &mocks.NetlinkManager{}
+			fakeMasterLink.Index = 2
+			existing := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName, Index: 7, ParentIndex: 2, MTU: 2044},
+				Pkey: 0xffff, Mode: netlink.IPOIB_MODE_DATAGRAM}
+			global := &net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)}
+			linkLocal := &net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(existing, nil)
+			mocked.On("AddrList", existing, netlink.FAMILY_ALL).Return([]netlink.Addr{
+				{IPNet: global, Scope: int(netlink.SCOPE_UNIVERSE)},
+				{IPNet: linkLocal, Scope: int(netlink.SCOPE_LINK)},
+			}, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Interface.Name).To(Equal(ifName))
+			Expect(ipoibLink.Interface.Sandbox).To(Equal("/proc/4123/ns/net"))
+			Expect(ipoibLink.Master).To(Equal("ib0"))
+			Expect(ipoibLink.Index).To(Equal(7))
+			Expect(ipoibLink.MTU).To(Equal(2044))
+			Expect(ipoibLink.Mode).To(Equal(types.IpoibModeDatagram))
+			Expect(ipoibLink.TempName).To(BeEmpty())
+			Expect(ipoibLink.PkeyMembership).To(Equal(types.PkeyMembershipFull))
+			Expect(ipoibLink.IPs).To(HaveLen(1))
+			Expect(ipoibLink.IPs[0].Address.String()).To(Equal("10.0.0.5/24"))
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+		})
+		It("Assuming interface of a previous ADD on the pkey parent in the pod", func() {
+			mocked := &mocks.NetlinkManager{}
+			netconf.Pkey = pkeyPtr(0x8001)
+			netconf.AutoCreateParent = true
+			parent := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 3}, Pkey: 0x8001}
+			existing := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName, Index: 7, ParentIndex: 3},
+				Pkey: 0x8001}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(existing, nil)
+			mocked.On("LinkByName", "ib0.8001").Return(parent, nil)
+			mocked.On("AddrList", existing, netlink.FAMILY_ALL).Return(nil, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Master).To(Equal("ib0.8001"))
+			Expect(ipoibLink.Pkey).To(Equal(types.Pkey(0x8001)))
+		})
+		It("Assuming interface of a previous ADD on another master of the pool", func() {
+			mocked := &mocks.NetlinkManager{}
+			netconf.Master = ""
+			netconf.Masters = []string{"ib0", "ib1"}
+			fakeMasterLink.Index = 2
+			ib1 := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib1", Index: 3}, Pkey: 0xffff}
+			existing := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName, Index: 7, ParentIndex: 3},
+				Pkey: 0xffff}
+			mocked.On("LinkByName", "ib0").Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", "ib1").Return(ib1, nil)
+			mocked.On("LinkByName", ifName).Return(existing, nil)
+			mocked.On("AddrList", existing, netlink.FAMILY_ALL).Return(nil, nil)
+
+			// the port of the master is not checked, the child is reused even if it went down
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Master).To(Equal("ib1"))
+			mockedSysfs.AssertNotCalled(GinkgoT(), "PortState", mock.Anything, mock.Anything)
+		})
+		It("Assuming interface of a previous ADD on the recorded master", func() {
+			mocked := &mocks.NetlinkManager{}
+			fakeMasterLink.Index = 2
+			ib1 := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib1", Index: 3}, Pkey: 0xffff}
+			existing := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName, Index: 7, ParentIndex: 3},
+				Pkey: 0xffff}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", "ib1").Return(ib1, nil)
+			mocked.On("LinkByName", ifName).Return(existing, nil)
+			mocked.On("AddrList", existing, netlink.FAMILY_ALL).Return(nil, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "ib1")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipoibLink.Master).To(Equal("ib1"))
+		})
+		It("Assuming interface of a previous ADD on a master the tenant policy no longer allows", func() {
+			mocked := &mocks.NetlinkManager{}
+			fakeMasterLink.Index = 2
+			netconf.Policy = &types.TenantPolicy{Namespace: "tenant-a", Masters: []string{"ib1"}}
+			existing := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName, Index: 7, ParentIndex: 2},
+				Pkey: 0xffff}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(existing, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")
+
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrPolicyViolation))
+			Expect(ipoibLink).To(BeNil())
+		})
+		It("Assuming interface of a previous ADD not matching the netconf", func() {
+			mocked := &mocks.NetlinkManager{}
+			fakeMasterLink.Index = 2
+			netconf.Mode = types.IpoibModeConnected
+			existing := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: ifName, Index: 7, ParentIndex: 2},
+				Pkey: 0xffff, Mode: netlink.IPOIB_MODE_DATAGRAM}
+			mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+			mocked.On("LinkByName", ifName).Return(existing, nil)
+
+			im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+			ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")
+
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrAlreadyExists))
+			Expect(cniErr.Details).To(ContainSubstring("mode"))
+			Expect(ipoibLink).To(BeNil())
+		})
+		DescribeTable("Assuming other interface with the name in the pod",
+			func(existing netlink.Link) {
+				mocked := &mocks.NetlinkManager{}
+				mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+				mocked.On("LinkByName", ifName).Return(existing, nil)
+
+				im := ipoibManager{nLink: mocked, sysfs: mockedSysfs}
+				ipoibLink, err := im.ReuseIpoibLink(netconf, ifName, newFakeNs(), "")
+
+				var cniErr *cniTypes.Error
+				Expect(errors.As(err, &cniErr)).To(BeTrue())
+				Expect(cniErr.Code).To(Equal(types.ErrAlreadyExists))
+				Expect(ipoibLink).To(BeNil())
+			},
+			Entry("not of type ipoib", &FakeLink{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}}),
+			Entry("other pkey", &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "eth0"}, Pkey: 0x8002}),
+			Entry("other master", &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "eth0", ParentIndex: 9},
+				Pkey: 0x7fff}),
+		)
+		DescribeTable("Assuming a failed step reverts the interface creation",
+			// The calls count both the steps and their inverses
+			func(failing string, dels, moves, renames, downs int) {
+				mocked := &mocks.NetlinkManager{}
+				fakeLink := &FakeLink{}
+				errorIf := func(method string) error {
+					if method == failing {
+						return errors.New("failed")
+					}
+					return nil
+				}
+
+				netconf.MTU = 1496
+				mocked.On("LinkByName", netconf.Master).Return(fakeMasterLink, nil)
+				mocked.On("LinkByName", ifName).Return(nil, netlink.LinkNotFoundError{}).Once().Maybe()
+				if failing == "refetch" {
+					mocked.On("LinkByName", ifName).Return(nil, errors.New("failed"))
+				}
+				mocked.On("LinkAdd", mock.Anything).Return(errorIf("LinkAdd"))
+				mocked.On("LinkByName", mock.AnythingOfType("string")).Return(fakeLink, nil)
+				mocked.On("LinkSetNsFd", fakeLink, mock.AnythingOfType("int")).Return(errorIf("LinkSetNsFd"))
+				mocked.On("SetSysVal", mock.AnythingOfType("string"), "1").Return("", errorIf("SetSysVal"))
+				mocked.On("LinkSetDown", fakeLink).Return(errorIf("LinkSetDown"))
+				mocked.On("LinkSetName", fakeLink, mock.AnythingOfType("string")).Return(errorIf("LinkSetName"))
+				mocked.On("LinkSetMTU", fakeLink, 1496).Return(errorIf("LinkSetMTU"))
+				mocked.On("LinkSetAlias", fakeLink, mock.AnythingOfType("string")).Return(errorIf("LinkSetAlias"))
+				mocked.On("LinkSetUp", fakeLink).Return(errorIf("LinkSetUp"))
+				mocked.On("LinkDel", fakeLink).Return(nil)
+
+				im := ipoibManager{nLink: mocked, sysfs: mockedSysfs, lockDir: GinkgoT().TempDir()}
+				ipoibLink, err := im.CreateIpoibLink(netconf, containerID, ifName, newFakeNs(), nil)
+
+				Expect(err).To(HaveOccurred())
+				Expect(ipoibLink).To(BeNil())
+				mocked.AssertNumberOfCalls(GinkgoT(), "LinkDel", dels)
+				mocked.AssertNumberOfCalls(GinkgoT(), "LinkSetNsFd", moves)
+				mocked.AssertNumberOfCalls(GinkgoT(), "LinkSetName", renames)
+				mocked.AssertNumberOfCalls(GinkgoT(), "LinkSetDown", downs)
+			},
+			Entry("LinkAdd", "LinkAdd", 0, 0, 0, 0),
+			Entry("LinkSetNsFd", "LinkSetNsFd", 1, 1, 0, 0),
+			Entry("SetSysVal", "SetSysVal", 1, 2, 0, 0),
+			Entry("LinkSetDown", "LinkSetDown", 1, 2, 0, 1),
+			Entry("LinkSetName", "LinkSetName", 1, 2, 1, 1),
+			Entry("LinkSetMTU", "LinkSetMTU", 1, 2, 2, 1),
+			Entry("LinkSetAlias", "LinkSetAlias", 1, 2, 2, 1),
+			Entry("LinkSetUp", "LinkSetUp", 1, 2, 2, 1),
+			Entry("refetch", "refetch", 1, 2, 2, 2),
+		)
+	})
+	Context("Checking masterName function", func() {
+		var (
+			netconf     *types.NetConf
+			mockedSysfs *mocks.SysfsManager
+			im          ipoibManager
+		)
+
+		BeforeEach(func() {
+			netconf = &types.NetConf{}
+			mockedSysfs = &mocks.SysfsManager{}
+			im = ipoibManager{nLink: &mocks.NetlinkManager{}, sysfs: mockedSysfs}
+		})
+
+		It("Assuming master netdev name", func() {
+			netconf.Master = "ib0"
+			Expect(im.masterName(netconf)).To(Equal("ib0"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming master PCI address with default port", func() {
+			netconf.MasterPci = "0000:03:00.0"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.0").Return([]string{"mlx5_0"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_0", 1).Return("ib0", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib0"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming master PCI address without RDMA device", func() {
+			netconf.MasterPci = "0000:03:00.0"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.0").Return([]string{}, nil)
+			_, err := im.masterName(netconf)
+			Expect(err).To(HaveOccurred())
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming master RDMA device and port", func() {
+			netconf.MasterRdmaDevice = "mlx5_2"
+			netconf.MasterPort = 2
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_2", 2).Return("ib3", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib3"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming master port GUID", func() {
+			netconf.MasterPortGUID = "0x0c42a10300175a3c"
+			mockedSysfs.On("RdmaPortByGUID", "0x0c42a10300175a3c").Return("mlx5_1", 1, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_1", 1).Return("ib1", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming deviceID only", func() {
+			netconf.DeviceID = "0000:03:00.1"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.1").Return([]string{"mlx5_1"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_1", 1).Return("ib1", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming deviceID matching master", func() {
+			netconf.Master = "ib1"
+			netconf.DeviceID = "0000:03:00.1"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.1").Return([]string{"mlx5_1"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_1", 1).Return("ib1", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming deviceID disagreeing with master", func() {
+			netconf.Master = "ib0"
+			netconf.DeviceID = "0000:03:00.1"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.1").Return([]string{"mlx5_1"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_1", 1).Return("ib1", nil)
+			_, err := im.masterName(netconf)
+			Expect(err).To(HaveOccurred())
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming deviceID without IPoIB interface", func() {
+			netconf.DeviceID = "0000:03:00.1"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.1").Return(nil, errors.New("not found"))
+			_, err := im.masterName(netconf)
+			Expect(err).To(HaveOccurred())
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming non existing master port GUID", func() {
+			netconf.MasterPortGUID = "0x0c42a10300175a3c"
+			mockedSysfs.On("RdmaPortByGUID", "0x0c42a10300175a3c").Return("", 0, errors.New("not found"))
+			_, err := im.masterName(netconf)
+			Expect(err).To(HaveOccurred())
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+	})
+	Context("Checking masters pool", func() {
+		var (
+			netconf       *types.NetConf
+			mockedSysfs   *mocks.SysfsManager
+			mockedNetlink *mocks.NetlinkManager
+			im            ipoibManager
+		)
+
+		BeforeEach(func() {
+			netconf = &types.NetConf{Masters: []string{"ib0", "ib1"}}
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedNetlink = &mocks.NetlinkManager{}
+			im = ipoibManager{nLink: mockedNetlink, sysfs: mockedSysfs}
+
+			mockedNetlink.On("LinkByName", "ib0").Return(&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 4}}, nil)
+			mockedNetlink.On("LinkByName", "ib1").Return(&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 5}}, nil)
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("mlx5_0", 1, nil)
+			mockedSysfs.On("RdmaPortByNetdev", "ib1").Return("mlx5_1", 1, nil)
+		})
+
+		It("Assuming failover to the second master", func() {
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("DOWN", nil)
+			mockedSysfs.On("PortState", "mlx5_1", 1).Return("ACTIVE", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming failover picks the first active master", func() {
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("ACTIVE", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib0"))
+			mockedSysfs.AssertNotCalled(GinkgoT(), "PortState", "mlx5_1", 1)
+		})
+		It("Assuming least loaded master", func() {
+			netconf.MasterSelection = types.MasterSelectionLeastLoaded
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("ACTIVE", nil)
+			mockedSysfs.On("PortState", "mlx5_1", 1).Return("ACTIVE", nil)
+			mockedNetlink.On("LinkList").Return([]netlink.Link{
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 10, ParentIndex: 4}},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 11, ParentIndex: 4}},
+				&netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Index: 12, ParentIndex: 5}},
+				&FakeLink{netlink.LinkAttrs{Index: 13, ParentIndex: 5}},
+				&FakeLink{netlink.LinkAttrs{Index: 14, ParentIndex: 5}},
+			}, nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+			mockedSysfs.AssertExpectations(GinkgoT())
+		})
+		It("Assuming no master with active port", func() {
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("DOWN", nil)
+			mockedSysfs.On("PortState", "mlx5_1", 1).Return("INIT", nil)
+			_, err := im.masterName(netconf)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming first active master not allowed by the tenant policy", func() {
+			netconf.Policy = &types.TenantPolicy{Namespace: "tenant-a", Masters: []string{"ib1"}}
+			mockedSysfs.On("PortState", "mlx5_1", 1).Return("ACTIVE", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+			mockedSysfs.AssertNotCalled(GinkgoT(), "PortState", "mlx5_0", 1)
+		})
+		It("Assuming no master allowed by the tenant policy", func() {
+			netconf.Policy = &types.TenantPolicy{Namespace: "tenant-a", Masters: []string{"ib2"}}
+			_, err := im.masterName(netconf)
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrPolicyViolation))
+		})
+		It("Assuming deviceID outside of the masters pool", func() {
+			netconf.DeviceID = "0000:03:00.2"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.2").Return([]string{"mlx5_2"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_2", 1).Return("ib2", nil)
+			_, err := im.masterName(netconf)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming deviceID inside of the masters pool", func() {
+			netconf.DeviceID = "0000:03:00.1"
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.1").Return([]string{"mlx5_1"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_1", 1).Return("ib1", nil)
+			Expect(im.masterName(netconf)).To(Equal("ib1"))
+		})
+	})
+	Context("Checking ensureParent function", func() {
+		var (
+			mocked  *mocks.NetlinkManager
+			baseLnk *netlink.IPoIB
+			im      ipoibManager
+		)
+
+		BeforeEach(func() {
+			mocked = &mocks.NetlinkManager{}
+			baseLnk = &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib0", Index: 4}, Pkey: 0xffff}
+			im = ipoibManager{nLink: mocked}
+		})
+
+		It("Assuming existing parent which is up", func() {
+			parent := &netlink.IPoIB{
+				LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 7, ParentIndex: 4, Flags: net.FlagUp},
+				Pkey:      0x8001,
+			}
+			mocked.On("LinkByName", "ib0.8001").Return(parent, nil)
+
+			name, lnk, err := im.ensureParent("ib0", baseLnk, 0x8001)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("ib0.8001"))
+			Expect(lnk).To(Equal(parent))
+			mocked.AssertNotCalled(GinkgoT(), "LinkAdd", mock.Anything)
+			mocked.AssertNotCalled(GinkgoT(), "LinkSetUp", mock.Anything)
+		})
+		It("Assuming existing parent which is down", func() {
+			parent := &netlink.IPoIB{
+				LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 7, ParentIndex: 4},
+				Pkey:      0x8001,
+			}
+			mocked.On("LinkByName", "ib0.8001").Return(parent, nil)
+			mocked.On("LinkSetUp", parent).Return(nil)
+
+			_, _, err := im.ensureParent("ib0", baseLnk, 0x8001)
+			Expect(err).NotTo(HaveOccurred())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming missing parent", func() {
+			parent := &netlink.IPoIB{
+				LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 7, ParentIndex: 4},
+				Pkey:      0x8001,
+			}
+			mocked.On("LinkByName", "ib0.8001").Return(nil, errors.New("not found")).Once()
+			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
+				return l.Name == "ib0.8001" && l.ParentIndex == 4 && l.Pkey == 0x8001
+			})).Return(nil)
+			mocked.On("LinkByName", "ib0.8001").Return(parent, nil).Once()
+			mocked.On("LinkSetUp", parent).Return(nil)
+
+			name, lnk, err := im.ensureParent("ib0", baseLnk, 0x8001)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("ib0.8001"))
+			Expect(lnk).To(Equal(parent))
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming limited pkey and parent created by a previous ADD", func() {
+			parent := &netlink.IPoIB{
+				LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 7, ParentIndex: 4, Flags: net.FlagUp},
+				Pkey:      0x8001,
+			}
+			mocked.On("LinkByName", "ib0.8001").Return(nil, errors.New("not found")).Once()
+			mocked.On("LinkAdd", mock.MatchedBy(func(l *netlink.IPoIB) bool {
+				return l.Name == "ib0.8001" && l.Pkey == 0x8001
+			})).Return(nil)
+			mocked.On("LinkByName", "ib0.8001").Return(parent, nil)
+
+			for range 2 {
+				name, lnk, err := im.ensureParent("ib0", baseLnk, 0x0001)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(name).To(Equal("ib0.8001"))
+				Expect(lnk).To(Equal(parent))
+			}
+			mocked.AssertNumberOfCalls(GinkgoT(), "LinkAdd", 1)
+		})
+		It("Assuming existing interface which is not a pkey child of the base master", func() {
+			other := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "ib0.8001", Index: 7, ParentIndex: 5}, Pkey: 0x8001}
+			mocked.On("LinkByName", "ib0.8001").Return(other, nil)
+
+			_, _, err := im.ensureParent("ib0", baseLnk, 0x8001)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming failed to create parent", func() {
+			mocked.On("LinkByName", "ib0.8001").Return(nil, errors.New("not found"))
+			mocked.On("LinkAdd", mock.Anything).Return(errors.New("failed"))
+
+			_, _, err := im.ensureParent("ib0", baseLnk, 0x8001)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming too long parent name", func() {
+			_, _, err := im.ensureParent("ibp129s0f0np0", baseLnk, 0x8001)
+			Expect(err).To(HaveOccurred())
+			mocked.AssertNotCalled(GinkgoT(), "LinkByName", mock.Anything)
+		})
+	})
+	DescribeTable("Checking childPkey function",
+		func(confPkey *types.Pkey, membership string, masterPkey, expected int) {
+			conf := &types.NetConf{Master: "ib0", Pkey: confPkey, PkeyMembership: membership}
+			master := &netlink.IPoIB{Pkey: uint16(masterPkey)}
+			Expect(childPkey(conf, master)).To(Equal(uint16(expected)))
+		},
+		Entry("master pkey without membership", nil, "", 0xffff, 0x7fff),
+		Entry("explicit pkey without membership", pkeyPtr(0x8001), "", 0xffff, 0x8001),
+		Entry("explicit limited pkey without membership", pkeyPtr(0x0001), "", 0xffff, 0x0001),
+		Entry("full membership", pkeyPtr(0x0001), types.PkeyMembershipFull, 0x7fff, 0x8001),
+		Entry("full membership of master pkey", nil, types.PkeyMembershipFull, 0xffff, 0xffff),
+		Entry("limited membership", pkeyPtr(0x8001), types.PkeyMembershipLimited, 0xffff, 0x0001),
+		Entry("inherit full membership", pkeyPtr(0x0001), types.PkeyMembershipInherit, 0xffff, 0x8001),
+		Entry("inherit limited membership", pkeyPtr(0x8001), types.PkeyMembershipInherit, 0x7fff, 0x0001),
+	)
+	DescribeTable("Checking childMTU function",
+		func(mtu int, policy, mode, masterMode string, masterMTU, expected int) {
+			conf := &types.NetConf{Master: "ib0", MTU: mtu, MTUPolicy: policy}
+			master := &netlink.IPoIB{
+				LinkAttrs: netlink.LinkAttrs{Name: "ib0", MTU: masterMTU},
+				Mode:      netlink.StringToIPoIBMode[masterMode],
+			}
+			got, err := childMTU(conf, netlink.StringToIPoIBMode[mode], master)
+			if expected < 0 {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal(expected))
+		},
+		Entry("inherited mtu", 0, "", types.IpoibModeDatagram, types.IpoibModeDatagram, 2044, 0),
+		Entry("mtu within the master mtu", 2044, "", types.IpoibModeDatagram, types.IpoibModeDatagram,
+			2044, 2044),
+		Entry("mtu above the datagram master mtu", 4092, types.MTUPolicyStrict, types.IpoibModeDatagram,
+			types.IpoibModeDatagram, 2044, -1),
+		Entry("clamped to the datagram master mtu", 4092, types.MTUPolicyClamp, types.IpoibModeDatagram,
+			types.IpoibModeDatagram, 2044, 2044),
+		Entry("mtu above the datagram limit", 9000, "", types.IpoibModeDatagram, types.IpoibModeConnected,
+			65520, -1),
+		Entry("clamped to the datagram limit", 9000, types.MTUPolicyClamp, types.IpoibModeDatagram,
+			types.IpoibModeConnected, 65520, 4092),
+		Entry("connected mtu above the datagram master mtu", 65520, "", types.IpoibModeConnected,
+			types.IpoibModeDatagram, 2044, 65520),
+	)
+	DescribeTable("Checking checkPolicy function",
+		func(policy *types.TenantPolicy, mtu int, allowed bool) {
+			conf := &types.NetConf{Master: "ib0", Pkey: pkeyPtr(0x8001), MTU: mtu, Policy: policy}
+			master := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{MTU: 2044}, Pkey: 0xffff}
+			err := checkPolicy(conf, "ib0", master)
+			if allowed {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			var cniErr *cniTypes.Error
+			Expect(errors.As(err, &cniErr)).To(BeTrue())
+			Expect(cniErr.Code).To(Equal(types.ErrPolicyViolation))
+		},
+		Entry("no policy", nil, 0, true),
+		Entry("empty policy", &types.TenantPolicy{}, 0, true),
+		Entry("allowed master", &types.TenantPolicy{Masters: []string{"ib1", "ib0"}}, 0, true),
+		Entry("not allowed master", &types.TenantPolicy{Masters: []string{"ib1"}}, 0, false),
+		Entry("allowed pkey", &types.TenantPolicy{Pkeys: []types.Pkey{0x0001}}, 0, true),
+		Entry("not allowed pkey", &types.TenantPolicy{Pkeys: []types.Pkey{0x8002}}, 0, false),
+		Entry("allowed mtu", &types.TenantPolicy{MTU: &types.MTURange{Min: 1500, Max: 4092}}, 4092, true),
+		Entry("mtu above range", &types.TenantPolicy{MTU: &types.MTURange{Max: 2000}}, 4092, false),
+		Entry("inherited mtu below range", &types.TenantPolicy{MTU: &types.MTURange{Min: 4092}}, 0, false),
+	)
+	Context("Checking ValidateIpoibLink function", func() {
+		var (
+			netconf  *types.NetConf
+			liveLink *netlink.IPoIB
+		)
+
+		BeforeEach(func() {
+			netconf = &types.NetConf{Master: "ib0"}
+			liveLink = &netlink.IPoIB{
+				LinkAttrs: netlink.LinkAttrs{Name: "eth0", MTU: 2044},
+				Pkey:      0x8001,
+				Mode:      netlink.IPOIB_MODE_DATAGRAM,
+				Umcast:    1,
+			}
+		})
+
+		It("Assuming matching attributes", func() {
+			umcast := true
+			netconf.IpoibAttrs = &types.IpoibAttrs{Pkey: pkeyPtr(0x8001), Mode: types.IpoibModeDatagram, Umcast: &umcast}
+			netconf.Pkey, netconf.Mode, netconf.Umcast = netconf.IpoibAttrs.Pkey, netconf.IpoibAttrs.Mode, &umcast
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).To(Succeed())
+		})
+		It("Assuming no attributes set", func() {
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).To(Succeed())
+		})
+		It("Assuming pkey partition matches with inherited membership", func() {
+			netconf.Pkey = pkeyPtr(0x0001)
+			netconf.PkeyMembership = types.PkeyMembershipInherit
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).To(Succeed())
+		})
+		It("Assuming different pkey", func() {
+			netconf.Pkey = pkeyPtr(0x8002)
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).NotTo(Succeed())
+		})
+		It("Assuming limited membership set to full by the kernel", func() {
+			netconf.Pkey = pkeyPtr(0x0001)
+			netconf.PkeyMembership = types.PkeyMembershipLimited
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).To(Succeed())
+		})
+		It("Assuming pkey read back on ADD", func() {
+			netconf.Pkey = pkeyPtr(0x0001)
+			Expect(ValidateIpoibLink(liveLink, netconf, &state.Attachment{Pkey: 0x8001})).To(Succeed())
+		})
+		It("Assuming pkey changed since ADD", func() {
+			netconf.Pkey = pkeyPtr(0x8001)
+			Expect(ValidateIpoibLink(liveLink, netconf, &state.Attachment{Pkey: 0x8002})).NotTo(Succeed())
+		})
+		It("Assuming different mode", func() {
+			netconf.Mode = types.IpoibModeConnected
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).NotTo(Succeed())
+		})
+		It("Assuming different umcast", func() {
+			umcast := false
+			netconf.Umcast = &umcast
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).NotTo(Succeed())
+		})
+		It("Assuming different mtu", func() {
+			liveLink.MTU = 2044
+			netconf.MTU = 4092
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).NotTo(Succeed())
+		})
+		It("Assuming clamped mtu", func() {
+			netconf.MTU = 4092
+			netconf.MTUPolicy = types.MTUPolicyClamp
+			Expect(ValidateIpoibLink(liveLink, netconf, nil)).To(Succeed())
+		})
+		It("Assuming link not of type ipoib", func() {
+			Expect(ValidateIpoibLink(&FakeLink{}, netconf, nil)).NotTo(Succeed())
+		})
+	})
+	Context("Checking CheckMaster function", func() {
+		var (
+			netconf       *types.NetConf
+			mockedSysfs   *mocks.SysfsManager
+			mockedNetlink *mocks.NetlinkManager
+			im            ipoibManager
+		)
+
+		BeforeEach(func() {
+			netconf = &types.NetConf{Master: "ib0"}
+			mockedSysfs = &mocks.SysfsManager{}
+			mockedNetlink = &mocks.NetlinkManager{}
+			im = ipoibManager{nLink: mockedNetlink, sysfs: mockedSysfs}
+			mockedSysfs.On("RdmaPortByNetdev", "ib0").Return("mlx5_0", 1, nil).Maybe()
+		})
+
+		It("Assuming ipoib master with an active port", func() {
+			mockedNetlink.On("LinkByName", "ib0").Return(&netlink.IPoIB{}, nil)
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("ACTIVE", nil)
+			Expect(im.CheckMaster(netconf)).To(Succeed())
+		})
+		It("Assuming missing master", func() {
+			mockedNetlink.On("LinkByName", "ib0").Return(nil, errors.New("Link not found"))
+			Expect(im.CheckMaster(netconf)).NotTo(Succeed())
+		})
+		It("Assuming master not of type ipoib", func() {
+			mockedNetlink.On("LinkByName", "ib0").Return(&FakeLink{}, nil)
+			Expect(im.CheckMaster(netconf)).NotTo(Succeed())
+		})
+		It("Assuming master port not active", func() {
+			mockedNetlink.On("LinkByName", "ib0").Return(&netlink.IPoIB{}, nil)
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("DOWN", nil)
+			Expect(im.CheckMaster(netconf)).To(MatchError(ContainSubstring("port 1 is DOWN")))
+		})
+		It("Assuming master chosen per pod by the runtime deviceID", func() {
+			// STATUS gets no runtime deviceID, the netconf selects no master
+			Expect(im.CheckMaster(&types.NetConf{})).To(Succeed())
+			mockedNetlink.AssertNotCalled(GinkgoT(), "LinkByName", mock.Anything)
+		})
+		It("Assuming master selected by masterPci", func() {
+			netconf = &types.NetConf{MasterPci: "0000:03:00.0"}
+			mockedSysfs.On("RdmaDevicesByPci", "0000:03:00.0").Return([]string{"mlx5_0"}, nil)
+			mockedSysfs.On("NetdevByRdmaPort", "mlx5_0", 1).Return("ib0", nil)
+			mockedNetlink.On("LinkByName", "ib0").Return(&netlink.IPoIB{}, nil)
+			mockedSysfs.On("PortState", "mlx5_0", 1).Return("DOWN", nil)
+			Expect(im.CheckMaster(netconf)).To(MatchError(ContainSubstring("port 1 is DOWN")))
+		})
 	})
 	Context("Checking RemoveIpoibLink function", func() {
 		var (
@@ -258,7 +1401,7 @@ var _ = Describe("IPoIB", func() {
 			targetNetNS := newFakeNs()
 			mocked := &mocks.NetlinkManager{}
 
-			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(nil, errors.New("not found"))
+			mocked.On("LinkByName", mock.AnythingOfType("string")).Return(nil, netlink.LinkNotFoundError{})
 
 			im := ipoibManager{nLink: mocked}
 			err := im.RemoveIpoibLink(ifName, targetNetNS)
@@ -266,6 +1409,23 @@ var _ = Describe("IPoIB", func() {
 			Expect(err).NotTo(HaveOccurred())
 			mocked.AssertExpectations(GinkgoT())
 		})
+		It("Assuming interface removed meanwhile", func() {
+			fakeLink := &FakeLink{netlink.LinkAttrs{}}
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", ifName).Return(fakeLink, nil)
+			mocked.On("LinkDel", fakeLink).Return(syscall.ENODEV)
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveIpoibLink(ifName, newFakeNs())).To(Succeed())
+		})
+		It("Assuming failed to look up the interface", func() {
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", ifName).Return(nil, errors.New("netlink socket closed"))
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveIpoibLink(ifName, newFakeNs())).NotTo(Succeed())
+			mocked.AssertNotCalled(GinkgoT(), "LinkDel", mock.Anything)
+		})
 		It("Assuming existing interface and failed to remove", func() {
 			targetNetNS := newFakeNs()
 			mocked := &mocks.NetlinkManager{}
@@ -280,5 +1440,75 @@ var _ = Describe("IPoIB", func() {
 			Expect(err).To(HaveOccurred())
 			mocked.AssertExpectations(GinkgoT())
 		})
+		It("Assuming existing interface with the recorded index", func() {
+			fakeLink := &FakeLink{netlink.LinkAttrs{Name: ifName, Index: 9}}
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", ifName).Return(fakeLink, nil)
+			mocked.On("LinkDel", fakeLink).Return(nil)
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveIpoibLinkWithIndex(ifName, 9, newFakeNs())).To(Succeed())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming existing interface of another ADD", func() {
+			fakeLink := &FakeLink{netlink.LinkAttrs{Name: ifName, Index: 12}}
+			mocked := &mocks.NetlinkManager{}
+			mocked.On("LinkByName", ifName).Return(fakeLink, nil)
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveIpoibLinkWithIndex(ifName, 9, newFakeNs())).To(Succeed())
+			mocked.AssertNotCalled(GinkgoT(), "LinkDel", mock.Anything)
+		})
+	})
+	Context("Checking RemoveHostLink function", func() {
+		var mocked *mocks.NetlinkManager
+
+		BeforeEach(func() {
+			mocked = &mocks.NetlinkManager{}
+		})
+
+		It("Assuming child left in the host namespace", func() {
+			leaked := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "veth0a1b2c3d"}}
+			mocked.On("LinkByName", "veth0a1b2c3d").Return(leaked, nil)
+			mocked.On("LinkDel", leaked).Return(nil)
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveHostLink("veth0a1b2c3d")).To(Succeed())
+			mocked.AssertExpectations(GinkgoT())
+		})
+		It("Assuming child moved to the pod", func() {
+			mocked.On("LinkByName", "veth0a1b2c3d").Return(nil, netlink.LinkNotFoundError{})
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveHostLink("veth0a1b2c3d")).To(Succeed())
+			mocked.AssertNotCalled(GinkgoT(), "LinkDel", mock.Anything)
+		})
+		It("Assuming reused child without temporary name", func() {
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveHostLink("")).To(Succeed())
+			mocked.AssertNotCalled(GinkgoT(), "LinkByName", mock.Anything)
+		})
+		It("Assuming host link of another owner with the same name", func() {
+			other := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "veth0a1b2c3d", Alias: "ipoib-cni:mynet:c0ffee:net1"}}
+			mocked.On("LinkByName", "veth0a1b2c3d").Return(other, nil)
+
+			im := ipoibManager{nLink: mocked}
+			Expect(im.RemoveHostLink("veth0a1b2c3d")).To(Succeed())
+			mocked.AssertNotCalled(GinkgoT(), "LinkDel", mock.Anything)
+		})
 	})
+	DescribeTable("Checking ValidateAttachment function",
+		func(index int, pkey uint16, valid bool) {
+			link := &netlink.IPoIB{LinkAttrs: netlink.LinkAttrs{Name: "net1", Index: index}, Pkey: pkey}
+			err := ValidateAttachment(link, &state.Attachment{IfIndex: 7, Pkey: 0x8001})
+			if valid {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		Entry("link created on ADD", 7, uint16(0x8001), true),
+		Entry("different index", 8, uint16(0x8001), false),
+		Entry("different pkey", 7, uint16(0x8002), false),
+	)
 })
@@ -0,0 +1,122 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipoib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/Mellanox/ipoib-cni/pkg/sysfs"
+)
+
+// MTUReconciler propagates the MTU changes of the IPoIB links of the host to the pod children which
+// inherited their MTU, children created with an explicit MTU are left alone
+type MTUReconciler struct {
+	im *ipoibManager
+	// mtus holds the last seen MTU of the IPoIB links of the host by index
+	mtus map[int]int
+}
+
+// NewMTUReconciler returns an MTUReconciler for the pod network namespaces pinned under netnsDir
+func NewMTUReconciler(netnsDir string) *MTUReconciler {
+	return &MTUReconciler{
+		im:   &ipoibManager{nLink: &netLink{}, sysfs: sysfs.New(sysfs.Root), netnsDir: netnsDir},
+		mtus: map[int]int{},
+	}
+}
+
+// Run reconciles the children of the existing IPoIB links of the host, then follows the link changes
+// until ctx is done
+func (r *MTUReconciler) Run(ctx context.Context) error {
+	updates := make(chan netlink.LinkUpdate)
+	done := make(chan struct{})
+	defer close(done)
+
+	err := netlink.LinkSubscribeWithOptions(updates, done, netlink.LinkSubscribeOptions{
+		ListExisting:  true,
+		ErrorCallback: func(err error) { slog.Error("link subscription failed", "error", err) },
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to link changes: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return fmt.Errorf("link subscription closed")
+			}
+			parent, ok := update.Link.(*netlink.IPoIB)
+			if !ok {
+				continue
+			}
+			if update.Header.Type == syscall.RTM_DELLINK {
+				delete(r.mtus, parent.Index)
+				continue
+			}
+			r.update(parent)
+		}
+	}
+}
+
+// update reconciles the children of parent if its MTU changed since it was last seen
+func (r *MTUReconciler) update(parent *netlink.IPoIB) {
+	if mtu, ok := r.mtus[parent.Index]; ok && mtu == parent.MTU {
+		return
+	}
+	r.mtus[parent.Index] = parent.MTU
+	if err := r.im.doInAllNetns(func() error { return r.reconcileNs(parent) }); err != nil {
+		slog.Error("failed to update inherited mtu", "parent", parent.Name, "error", err)
+	}
+}
+
+// reconcileNs sets the MTU of parent, within the limit of their mode, on its children in the current
+// network namespace which inherited their MTU
+func (r *MTUReconciler) reconcileNs(parent *netlink.IPoIB) error {
+	links, err := r.im.nLink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %v", err)
+	}
+
+	var errs []error
+	for _, link := range links {
+		child, ok := link.(*netlink.IPoIB)
+		if !ok || child.ParentIndex != parent.Index {
+			continue
+		}
+		if alias, ok := parseChildAlias(child.Alias); !ok || !alias.InheritedMTU {
+			continue
+		}
+		mtu := min(parent.MTU, mtuLimit(child.Mode, parent))
+		if child.MTU == mtu {
+			continue
+		}
+		if err = r.im.nLink.LinkSetMTU(child, mtu); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set MTU %d on interface %q: %v", mtu, child.Name, err))
+			continue
+		}
+		slog.Info("updated inherited mtu", "interface", child.Name, "parent", parent.Name, "mtu", mtu)
+	}
+	return errors.Join(errs...)
+}
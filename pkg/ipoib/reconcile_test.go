@@ -0,0 +1,102 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipoib
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/mock"
+	"github.com/vishvananda/netlink"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types/mocks"
+)
+
+var _ = Describe("MTUReconciler", func() {
+	const inheritedMTU = "ipoib-cni:mynet:c0ffee:net1:inherited-mtu"
+
+	var (
+		mocked *mocks.NetlinkManager
+		r      *MTUReconciler
+		parent *netlink.IPoIB
+	)
+
+	child := func(name string, mtu int, mode netlink.IPoIBMode, alias string) *netlink.IPoIB {
+		return &netlink.IPoIB{
+			LinkAttrs: netlink.LinkAttrs{Name: name, ParentIndex: 2, MTU: mtu, Alias: alias},
+			Mode:      mode,
+		}
+	}
+
+	BeforeEach(func() {
+		mocked = &mocks.NetlinkManager{}
+		r = &MTUReconciler{im: &ipoibManager{nLink: mocked}, mtus: map[int]int{}}
+		parent = &netlink.IPoIB{
+			LinkAttrs: netlink.LinkAttrs{Name: "ib0", Index: 2, MTU: 4092},
+			Mode:      netlink.IPOIB_MODE_DATAGRAM,
+		}
+	})
+
+	It("Assuming children which inherited the parent MTU", func() {
+		inherited := child("net1", 2044, netlink.IPOIB_MODE_DATAGRAM, inheritedMTU)
+		explicit := child("net2", 2044, netlink.IPOIB_MODE_DATAGRAM, "ipoib-cni:mynet:c0ffee:net2")
+		other := child("net3", 2044, netlink.IPOIB_MODE_DATAGRAM, inheritedMTU)
+		other.ParentIndex = 3
+		mocked.On("LinkList").Return([]netlink.Link{parent, inherited, explicit, other}, nil)
+		mocked.On("LinkSetMTU", inherited, 4092).Return(nil)
+
+		Expect(r.reconcileNs(parent)).To(Succeed())
+		mocked.AssertExpectations(GinkgoT())
+		mocked.AssertNumberOfCalls(GinkgoT(), "LinkSetMTU", 1)
+	})
+	It("Assuming parent MTU above the datagram limit", func() {
+		parent.Mode = netlink.IPOIB_MODE_CONNECTED
+		parent.MTU = 65520
+		datagram := child("net1", 2044, netlink.IPOIB_MODE_DATAGRAM, inheritedMTU)
+		connected := child("net2", 2044, netlink.IPOIB_MODE_CONNECTED, inheritedMTU)
+		mocked.On("LinkList").Return([]netlink.Link{datagram, connected}, nil)
+		mocked.On("LinkSetMTU", datagram, 4092).Return(nil)
+		mocked.On("LinkSetMTU", connected, 65520).Return(nil)
+
+		Expect(r.reconcileNs(parent)).To(Succeed())
+		mocked.AssertExpectations(GinkgoT())
+	})
+	It("Assuming failed update of one of the children", func() {
+		failing := child("net1", 2044, netlink.IPOIB_MODE_DATAGRAM, inheritedMTU)
+		updated := child("net2", 2044, netlink.IPOIB_MODE_DATAGRAM, inheritedMTU)
+		mocked.On("LinkList").Return([]netlink.Link{failing, updated}, nil)
+		mocked.On("LinkSetMTU", failing, 4092).Return(errors.New("failed"))
+		mocked.On("LinkSetMTU", updated, 4092).Return(nil)
+
+		Expect(r.reconcileNs(parent)).To(MatchError(ContainSubstring(`interface "net1"`)))
+		mocked.AssertExpectations(GinkgoT())
+	})
+	It("Assuming link updates without a change of the parent MTU", func() {
+		inherited := child("net1", 2044, netlink.IPOIB_MODE_DATAGRAM, inheritedMTU)
+		mocked.On("LinkList").Return([]netlink.Link{inherited}, nil)
+		mocked.On("LinkSetMTU", inherited, mock.AnythingOfType("int")).Return(nil)
+
+		r.update(parent)
+		r.update(parent)
+		parent.MTU = 2044
+		r.update(parent)
+
+		mocked.AssertNumberOfCalls(GinkgoT(), "LinkList", 2)
+		mocked.AssertNumberOfCalls(GinkgoT(), "LinkSetMTU", 1)
+	})
+})
@@ -0,0 +1,39 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipoib
+
+import "errors"
+
+// undoStack holds the inverses of the completed steps of an operation
+type undoStack []func() error
+
+// push registers the inverse of a completed step
+func (u *undoStack) push(inverse func() error) {
+	*u = append(*u, inverse)
+}
+
+// unwind runs the inverses in the reverse order of their steps, even if some fail, and empties the stack
+func (u *undoStack) unwind() error {
+	var errs []error
+	for i := len(*u) - 1; i >= 0; i-- {
+		if err := (*u)[i](); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	*u = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,45 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ipoib
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("undoStack", func() {
+	It("Assuming inverses registered by completed steps", func() {
+		var undo undoStack
+		var order []int
+		for i := range 3 {
+			undo.push(func() error {
+				order = append(order, i)
+				if i == 1 {
+					return errors.New("failed")
+				}
+				return nil
+			})
+		}
+
+		Expect(undo.unwind()).To(MatchError("failed"))
+		Expect(order).To(Equal([]int{2, 1, 0}))
+		Expect(undo).To(BeEmpty())
+		Expect(undo.unwind()).To(Succeed())
+	})
+})
@@ -0,0 +1,263 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package journal records the steps of ADD before they are taken, so that an ADD interrupted by a crash
+// can be rolled back by the next invocation
+package journal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Dir is where the plugin keeps the journals of the ADDs in progress
+const Dir = "/var/lib/cni/ipoib/journal"
+
+const (
+	fileSuffix = ".json"
+	lockSuffix = ".lock"
+)
+
+// Step is a step of ADD which has to be undone if ADD is interrupted
+type Step string
+
+// Steps of ADD
+const (
+	// StepLink creates the child link with a temporary name, moves it to the pod and renames it
+	StepLink Step = "link"
+	// StepIPAM allocates the addresses with the IPAM plugin
+	StepIPAM Step = "ipam"
+)
+
+// Intent is the journal of an ADD
+type Intent struct {
+	ContainerID string `json:"containerID"`
+	IfName      string `json:"ifName"`
+	Netns       string `json:"netns"`
+	// Args and Path are CNI_ARGS and CNI_PATH, needed to release the addresses with the IPAM plugin
+	Args     string          `json:"args,omitempty"`
+	Path     string          `json:"path,omitempty"`
+	Netconf  json.RawMessage `json:"netconf"`
+	IPAMType string          `json:"ipamType,omitempty"`
+	// Steps are the steps started, in order
+	Steps    []Step `json:"steps,omitempty"`
+	TempName string `json:"tempName,omitempty"`
+	// IfIndex is the index of the child link in the pod network namespace, rollback only deletes the link
+	// named IfName with this index, a link with another index belongs to another ADD
+	IfIndex int `json:"ifIndex,omitempty"`
+
+	journal *Journal
+	// name is the file name of the intent, without the suffix
+	name string
+	// lock is held by the process running the ADD, or rolling it back, for as long as it does
+	lock *os.File
+}
+
+// Journal keeps an intent for each ADD in progress in a JSON file named <containerID>-<ifName>-<id>.json,
+// unique per invocation. The process running the ADD holds a lock on the <containerID>-<ifName>-<id>.lock
+// file beside it until the ADD returns, an intent whose lock is free belongs to an interrupted ADD.
+type Journal struct {
+	dir string
+}
+
+// New returns a Journal keeping the intents in dir
+func New(dir string) *Journal {
+	return &Journal{dir: dir}
+}
+
+func (j *Journal) path(name string) string {
+	return filepath.Join(j.dir, name+fileSuffix)
+}
+
+func (j *Journal) lockPath(name string) string {
+	return filepath.Join(j.dir, name+lockSuffix)
+}
+
+// Begin records that the ADD of the intent started, and locks the intent until Finish
+func (j *Journal) Begin(in *Intent) error {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("failed to generate journal id: %v", err)
+	}
+	if err := os.MkdirAll(j.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create journal directory %q: %v", j.dir, err)
+	}
+	in.journal = j
+	in.name = in.ContainerID + "-" + in.IfName + "-" + hex.EncodeToString(id)
+
+	// the intent is written once locked, so that it is never seen unlocked while the ADD runs
+	lock, acquired, err := j.tryLock(in.name)
+	if err == nil && !acquired {
+		err = fmt.Errorf("locked by another process")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock journal %q: %v", in.name, err)
+	}
+	in.lock = lock
+	if err = j.save(in); err != nil {
+		_ = j.Finish(in)
+		return err
+	}
+	return nil
+}
+
+// Finish removes the intent once ADD completed, or was rolled back, and releases its lock
+func (j *Journal) Finish(in *Intent) error {
+	// the intent is removed before the lock is released, a process waiting for the lock finds it gone
+	if err := os.Remove(j.path(in.name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal: %v", err)
+	}
+	if in.lock != nil {
+		_ = os.Remove(j.lockPath(in.name))
+	}
+	j.Release(in)
+	return nil
+}
+
+// Release releases the lock of the intent and keeps it, e.g. for a rollback to be retried
+func (j *Journal) Release(in *Intent) {
+	if in.lock != nil {
+		_ = in.lock.Close()
+		in.lock = nil
+	}
+}
+
+// Record records that the step is about to start
+func (in *Intent) Record(step Step) error {
+	in.Steps = append(in.Steps, step)
+	return in.journal.save(in)
+}
+
+// RecordLink records that the child link is about to be created with the temporary name
+func (in *Intent) RecordLink(tempName string) error {
+	in.TempName = tempName
+	return in.Record(StepLink)
+}
+
+// RecordIndex records the index of the child link moved to the pod network namespace, before it is renamed
+func (in *Intent) RecordIndex(index int) error {
+	in.IfIndex = index
+	return in.journal.save(in)
+}
+
+// Started checks if the step was started
+func (in *Intent) Started(step Step) bool {
+	return slices.Contains(in.Steps, step)
+}
+
+func (j *Journal) save(in *Intent) error {
+	if err := os.MkdirAll(j.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create journal directory %q: %v", j.dir, err)
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %v", err)
+	}
+
+	path := j.path(in.name)
+	tmp, err := os.CreateTemp(j.dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create journal: %v", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write journal %q: %v", path, err)
+	}
+	return nil
+}
+
+// Unfinished returns the intents of the ADDs interrupted before they completed, the ones whose lock is free.
+// The returned intents are locked by the caller, which must Finish or Release them. Unreadable intents are
+// skipped, they cannot be rolled back.
+func (j *Journal) Unfinished() ([]*Intent, error) {
+	files, err := os.ReadDir(j.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list journal directory %q: %v", j.dir, err)
+	}
+
+	var intents []*Intent
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), fileSuffix)
+		if file.IsDir() || !ok {
+			continue
+		}
+		if in := j.lockUnfinished(name); in != nil {
+			intents = append(intents, in)
+		}
+	}
+	return intents, nil
+}
+
+// lockUnfinished locks and reads the intent, it returns nil if the intent is locked by another process,
+// finished meanwhile or unreadable
+func (j *Journal) lockUnfinished(name string) *Intent {
+	lock, acquired, err := j.tryLock(name)
+	if err != nil || !acquired {
+		return nil
+	}
+	in := &Intent{journal: j, name: name, lock: lock}
+	// read once locked, the ADD may have finished since the directory was listed
+	data, err := os.ReadFile(j.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			_ = os.Remove(j.lockPath(name))
+		}
+		j.Release(in)
+		return nil
+	}
+	if err = json.Unmarshal(data, in); err != nil {
+		j.Release(in)
+		return nil
+	}
+	return in
+}
+
+// tryLock locks the lock file of the intent without blocking, it returns whether the lock was acquired
+func (j *Journal) tryLock(name string) (*os.File, bool, error) {
+	//nolint:gosec // the path is built from the journal directory
+	file, err := os.OpenFile(j.lockPath(name), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, false, err
+	}
+	//nolint:gosec // fd values fit in int
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return file, true, nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		dir string
+		j   *Journal
+		in  *Intent
+	)
+
+	BeforeEach(func() {
+		dir = filepath.Join(GinkgoT().TempDir(), "journal")
+		j = New(dir)
+		in = &Intent{ContainerID: "c0ffee", IfName: "net1", Netns: "/var/run/netns/pod",
+			Netconf: json.RawMessage(`{"name":"mynet"}`), IPAMType: "host-local"}
+	})
+
+	// interrupt releases the lock of the intent as if the process running the ADD was gone
+	interrupt := func() {
+		j.Release(in)
+	}
+
+	It("records the steps of an ADD", func() {
+		Expect(j.Begin(in)).To(Succeed())
+		Expect(in.RecordLink("veth0a1b2c3d")).To(Succeed())
+		Expect(in.Record(StepIPAM)).To(Succeed())
+		interrupt()
+
+		intents, err := j.Unfinished()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(intents).To(HaveLen(1))
+		Expect(intents[0].TempName).To(Equal("veth0a1b2c3d"))
+		Expect(intents[0].Started(StepLink)).To(BeTrue())
+		Expect(intents[0].Started(StepIPAM)).To(BeTrue())
+		Expect(intents[0].Netconf).To(MatchJSON(`{"name":"mynet"}`))
+	})
+	It("does not report the ADDs in progress", func() {
+		Expect(j.Begin(in)).To(Succeed())
+		Expect(j.Unfinished()).To(BeEmpty())
+	})
+	It("keeps the intents of concurrent ADDs of the same attachment apart", func() {
+		retry := &Intent{ContainerID: "c0ffee", IfName: "net1", Netns: "/var/run/netns/pod"}
+		Expect(j.Begin(in)).To(Succeed())
+		Expect(j.Begin(retry)).To(Succeed())
+		Expect(retry.RecordLink("veth4e5f6071")).To(Succeed())
+		interrupt()
+
+		intents, err := j.Unfinished()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(intents).To(HaveLen(1))
+		Expect(intents[0].Started(StepLink)).To(BeFalse())
+	})
+	It("reports an interrupted ADD to a single caller until it is released", func() {
+		Expect(j.Begin(in)).To(Succeed())
+		interrupt()
+
+		intents, err := j.Unfinished()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(intents).To(HaveLen(1))
+		Expect(j.Unfinished()).To(BeEmpty())
+		j.Release(intents[0])
+		Expect(j.Unfinished()).To(HaveLen(1))
+	})
+	It("does not report a finished ADD", func() {
+		Expect(j.Begin(in)).To(Succeed())
+		Expect(j.Finish(in)).To(Succeed())
+		Expect(j.Unfinished()).To(BeEmpty())
+		Expect(j.Finish(in)).To(Succeed())
+		Expect(os.ReadDir(dir)).To(BeEmpty())
+	})
+	It("skips unreadable intents", func() {
+		Expect(os.MkdirAll(dir, 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "c0ffee-net1-0011223344556677.json"), []byte("{"), 0o600)).
+			To(Succeed())
+		Expect(j.Unfinished()).To(BeEmpty())
+	})
+	It("reports nothing without a journal directory", func() {
+		Expect(j.Unfinished()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,55 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Dir is where the plugin keeps its lock files
+const Dir = "/var/run/ipoib-cni"
+
+// FileLock is an exclusive advisory lock on a file, shared by all plugin invocations on the node
+type FileLock struct {
+	file *os.File
+}
+
+// Lock blocks until it acquires the lock on the file at path, the file and its directory are created if missing
+func Lock(path string) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory for %q: %v", path, err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600) //nolint:gosec // path is built by the plugin
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %q: %v", path, err)
+	}
+
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil { //nolint:gosec // fd values fit in int
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to lock %q: %v", path, err)
+	}
+	return &FileLock{file: file}, nil
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	// closing the file releases the lock
+	return l.file.Close()
+}
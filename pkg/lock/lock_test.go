@@ -0,0 +1,62 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lock", func() {
+	var lockPath string
+
+	BeforeEach(func() {
+		tmpDir, err := os.MkdirTemp("", "lock-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, tmpDir)
+		lockPath = filepath.Join(tmpDir, "subdir", "ib0.lock")
+	})
+
+	It("creates the lock file and its directory", func() {
+		l, err := Lock(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lockPath).To(BeAnExistingFile())
+		Expect(l.Unlock()).To(Succeed())
+	})
+
+	It("blocks until the lock is released", func() {
+		l, err := Lock(lockPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		acquired := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			second, err := Lock(lockPath)
+			Expect(err).NotTo(HaveOccurred())
+			close(acquired)
+			Expect(second.Unlock()).To(Succeed())
+		}()
+
+		Consistently(acquired, 200*time.Millisecond).ShouldNot(BeClosed())
+		Expect(l.Unlock()).To(Succeed())
+		Eventually(acquired, 2*time.Second).Should(BeClosed())
+	})
+})
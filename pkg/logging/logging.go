@@ -0,0 +1,111 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log levels of the netconf logLevel field
+const (
+	LevelDebug   = "debug"
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// logFile is the log file opened by Init, kept open for the lifetime of the process
+var logFile *os.File
+
+// Init sets the default logger to log messages of level and above to file, or to stderr if file is
+// empty. The level defaults to warning.
+func Init(level, file string) error {
+	lvl, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	w, err := output(file)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: lvl})))
+	return nil
+}
+
+// output returns the writer of the logs, the log file is only opened again when it changes
+func output(file string) (io.Writer, error) {
+	if file == "" {
+		return os.Stderr, nil
+	}
+	if logFile != nil && logFile.Name() == file {
+		return logFile, nil
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600) //nolint:gosec // set by the admin
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file %q: %v", file, err)
+	}
+	if logFile != nil {
+		_ = logFile.Close()
+	}
+	logFile = f
+	return f, nil
+}
+
+// ValidateLevel checks that level is one of the supported log levels, or empty
+func ValidateLevel(level string) error {
+	_, err := parseLevel(level)
+	return err
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug, nil
+	case LevelInfo:
+		return slog.LevelInfo, nil
+	case "", LevelWarning:
+		return slog.LevelWarn, nil
+	case LevelError:
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid logLevel %q: must be one of %q, %q, %q or %q", level,
+		LevelDebug, LevelInfo, LevelWarning, LevelError)
+}
+
+// JSON returns a log value which logs v as JSON, v is only marshaled if the message is logged
+func JSON(v any) slog.LogValuer {
+	return jsonValue{v: v}
+}
+
+type jsonValue struct {
+	v any
+}
+
+// LogValue implements slog.LogValuer
+func (j jsonValue) LogValue() slog.Value {
+	data, err := json.Marshal(j.v)
+	if err != nil {
+		return slog.StringValue(fmt.Sprintf("failed to marshal: %v", err))
+	}
+	return slog.StringValue(string(data))
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logging", func() {
+	BeforeEach(func() {
+		logger := slog.Default()
+		DeferCleanup(func() { slog.SetDefault(logger) })
+	})
+
+	It("logs messages of the level and above to the file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "ipoib.log")
+		Expect(Init(LevelInfo, path)).To(Succeed())
+
+		slog.Debug("debug message")
+		slog.Info("info message", "master", "ib0")
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("debug message"))
+		Expect(string(data)).To(ContainSubstring(`msg="info message" master=ib0`))
+	})
+	It("defaults to warning level", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "ipoib.log")
+		Expect(Init("", path)).To(Succeed())
+
+		slog.Info("info message")
+		slog.Warn("warning message")
+
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("info message"))
+		Expect(string(data)).To(ContainSubstring("warning message"))
+	})
+	It("opens the log file once", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "ipoib.log")
+		Expect(Init(LevelInfo, path)).To(Succeed())
+		f := logFile
+		Expect(Init(LevelDebug, path)).To(Succeed())
+		Expect(logFile).To(BeIdenticalTo(f))
+
+		slog.Debug("debug message")
+		data, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("debug message"))
+	})
+	It("rejects invalid level", func() {
+		Expect(Init("verbose", "")).NotTo(Succeed())
+		Expect(ValidateLevel("verbose")).NotTo(Succeed())
+	})
+	It("fails when the log file can not be opened", func() {
+		Expect(Init(LevelInfo, filepath.Join(GinkgoT().TempDir(), "missing", "ipoib.log"))).NotTo(Succeed())
+	})
+})
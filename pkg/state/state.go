@@ -0,0 +1,153 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+// Package state keeps what ADD created for each attachment, so that DEL, CHECK and GC act on it rather
+// than on the netconf they are given
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	current "github.com/containernetworking/cni/pkg/types/100"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+// Dir is where the plugin keeps the state of the attachments
+const Dir = "/var/lib/cni/ipoib"
+
+const fileSuffix = ".json"
+
+// Attachment is the state of an attachment, written on ADD
+type Attachment struct {
+	ContainerID string `json:"containerID"`
+	IfName      string `json:"ifName"`
+	Network     string `json:"network"`
+	Netns       string `json:"netns"`
+	// Master is the parent of the child link, the pkey parent if created by autoCreateParent
+	Master string     `json:"master"`
+	Pkey   types.Pkey `json:"pkey"`
+	// IfIndex is the index of the child link in the pod network namespace
+	IfIndex int `json:"ifIndex"`
+	// TempName is the name of the child link in the host network namespace before it was moved
+	TempName string `json:"tempName"`
+	IPAMType string `json:"ipamType,omitempty"`
+	// Result is the result of ADD, which a retried ADD reusing the child reports again
+	Result *current.Result `json:"result,omitempty"`
+}
+
+// Store keeps the state of each attachment in a JSON file named <containerID>-<ifName>.json
+type Store struct {
+	dir string
+}
+
+// New returns a Store keeping the state files in dir
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+func (s *Store) path(containerID, ifName string) string {
+	return filepath.Join(s.dir, containerID+"-"+ifName+fileSuffix)
+}
+
+// Save writes the state of the attachment, replacing the previous one atomically
+func (s *Store) Save(a *Attachment) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory %q: %v", s.dir, err)
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %v", err)
+	}
+
+	path := s.path(a.ContainerID, a.IfName)
+	tmp, err := os.CreateTemp(s.dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %v", err)
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file %q: %v", path, err)
+	}
+	return nil
+}
+
+// Load returns the state of the attachment, nil if there is none
+func (s *Store) Load(containerID, ifName string) (*Attachment, error) {
+	return s.load(s.path(containerID, ifName))
+}
+
+func (s *Store) load(path string) (*Attachment, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // the path is built by the store
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+	a := &Attachment{}
+	if err = json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("failed to load state file %q: %v", path, err)
+	}
+	return a, nil
+}
+
+// Delete removes the state of the attachment, if any
+func (s *Store) Delete(containerID, ifName string) error {
+	if err := os.Remove(s.path(containerID, ifName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %v", err)
+	}
+	return nil
+}
+
+// List returns the state of all the attachments
+func (s *Store) List() ([]*Attachment, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list state directory %q: %v", s.dir, err)
+	}
+
+	var attachments []*Attachment
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileSuffix) {
+			continue
+		}
+		a, err := s.load(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if a != nil {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var (
+		dir   string
+		store *Store
+		a     *Attachment
+	)
+
+	BeforeEach(func() {
+		dir = filepath.Join(GinkgoT().TempDir(), "ipoib")
+		store = New(dir)
+		a = &Attachment{ContainerID: "c0ffee", IfName: "net1", Network: "mynet", Netns: "/var/run/netns/pod",
+			Master: "ib0", Pkey: 0x8001, IfIndex: 7, TempName: "veth0a1b2c3d", IPAMType: "host-local"}
+	})
+
+	It("saves and loads an attachment", func() {
+		Expect(store.Save(a)).To(Succeed())
+		Expect(filepath.Join(dir, "c0ffee-net1.json")).To(BeARegularFile())
+		Expect(store.Load("c0ffee", "net1")).To(Equal(a))
+	})
+	It("replaces the saved attachment", func() {
+		Expect(store.Save(a)).To(Succeed())
+		a.IfIndex = 8
+		Expect(store.Save(a)).To(Succeed())
+		Expect(store.Load("c0ffee", "net1")).To(HaveField("IfIndex", 8))
+		Expect(os.ReadDir(dir)).To(HaveLen(1))
+	})
+	It("loads nothing for an unknown attachment", func() {
+		Expect(store.Load("c0ffee", "net1")).To(BeNil())
+	})
+	It("fails to load a corrupted state file", func() {
+		Expect(os.MkdirAll(dir, 0o700)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "c0ffee-net1.json"), []byte("{"), 0o600)).To(Succeed())
+		_, err := store.Load("c0ffee", "net1")
+		Expect(err).To(HaveOccurred())
+	})
+	It("deletes an attachment", func() {
+		Expect(store.Save(a)).To(Succeed())
+		Expect(store.Delete("c0ffee", "net1")).To(Succeed())
+		Expect(store.Load("c0ffee", "net1")).To(BeNil())
+		Expect(store.Delete("c0ffee", "net1")).To(Succeed())
+	})
+	It("lists the attachments", func() {
+		Expect(store.List()).To(BeEmpty())
+		b := *a
+		b.IfName = "net2"
+		Expect(store.Save(a)).To(Succeed())
+		Expect(store.Save(&b)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o600)).To(Succeed())
+		Expect(store.List()).To(ConsistOf(a, &b))
+	})
+})
@@ -0,0 +1,235 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sysfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+const (
+	// Root is the path sysfs is mounted at
+	Root = "/sys"
+
+	pciDevicesDir      = "bus/pci/devices"
+	infinibandClassDir = "class/infiniband"
+	netClassDir        = "class/net"
+
+	// arphrdInfiniband is the link type of IPoIB netdevs, ARPHRD_INFINIBAND
+	arphrdInfiniband = "32"
+	// gidGroups is the number of colon separated groups in a GID
+	gidGroups = 8
+)
+
+// PortStateActive is the state of an RDMA port which is up and configured by the subnet manager
+const PortStateActive = "ACTIVE"
+
+type sysfs struct {
+	root string
+}
+
+// New returns a SysfsManager reading the sysfs tree mounted at root
+func New(root string) types.SysfsManager {
+	return &sysfs{root: root}
+}
+
+// RdmaDevicesByPci returns the RDMA devices of a PCI device
+func (s *sysfs) RdmaDevicesByPci(pciAddr string) ([]string, error) {
+	devs, err := s.list(pciDevicesDir, pciAddr, "infiniband")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find RDMA devices of PCI device %s: %v", pciAddr, err)
+	}
+	return devs, nil
+}
+
+// NetdevByRdmaPort returns the IPoIB netdev of an RDMA device port, pkey child netdevs are skipped
+func (s *sysfs) NetdevByRdmaPort(rdmaDev string, port int) (string, error) {
+	netdevs, err := s.list(infinibandClassDir, rdmaDev, "device", "net")
+	if err != nil {
+		return "", fmt.Errorf("failed to find netdevs of RDMA device %s: %v", rdmaDev, err)
+	}
+
+	// dev_port is zero based while RDMA ports are numbered from one
+	devPort := strconv.Itoa(port - 1)
+	for _, netdev := range netdevs {
+		if !s.isIpoibParent(netdev) {
+			continue
+		}
+		if attr, err := s.readAttr(netClassDir, netdev, "dev_port"); err == nil && attr == devPort {
+			return netdev, nil
+		}
+	}
+	return "", fmt.Errorf("no IPoIB netdev found for RDMA device %s port %d", rdmaDev, port)
+}
+
+// RdmaPortByGUID returns the RDMA device and port with the given port GUID
+func (s *sysfs) RdmaPortByGUID(guid string) (string, int, error) {
+	guid = normalizeGUID(guid)
+	devs, err := s.list(infinibandClassDir)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to list RDMA devices: %v", err)
+	}
+
+	for _, dev := range devs {
+		ports, err := s.list(infinibandClassDir, dev, "ports")
+		if err != nil {
+			continue
+		}
+		for _, port := range ports {
+			gid, err := s.readAttr(infinibandClassDir, dev, "ports", port, "gids", "0")
+			if err != nil || portGUID(gid) != guid {
+				continue
+			}
+			portNum, err := strconv.Atoi(port)
+			if err != nil {
+				return "", 0, fmt.Errorf("unexpected port %q of RDMA device %s: %v", port, dev, err)
+			}
+			return dev, portNum, nil
+		}
+	}
+	return "", 0, fmt.Errorf("no RDMA port found with port GUID %s", guid)
+}
+
+// PortGUID returns the port GUID of an RDMA device port, as 16 lower case hex digits
+func (s *sysfs) PortGUID(rdmaDev string, port int) (string, error) {
+	gid, err := s.readAttr(infinibandClassDir, rdmaDev, "ports", strconv.Itoa(port), "gids", "0")
+	if err != nil {
+		return "", fmt.Errorf("failed to read port GUID of RDMA device %s port %d: %v", rdmaDev, port, err)
+	}
+	guid := portGUID(gid)
+	if guid == "" {
+		return "", fmt.Errorf("unexpected GID %q of RDMA device %s port %d", gid, rdmaDev, port)
+	}
+	return guid, nil
+}
+
+// RdmaPortByNetdev returns the RDMA device and port of an IPoIB netdev
+func (s *sysfs) RdmaPortByNetdev(netdev string) (string, int, error) {
+	devs, err := s.list(netClassDir, netdev, "device", "infiniband")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to find RDMA device of netdev %s: %v", netdev, err)
+	}
+	if len(devs) != 1 {
+		return "", 0, fmt.Errorf("expected a single RDMA device for netdev %s, found %d", netdev, len(devs))
+	}
+
+	attr, err := s.readAttr(netClassDir, netdev, "dev_port")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read port of netdev %s: %v", netdev, err)
+	}
+	devPort, err := strconv.Atoi(attr)
+	if err != nil {
+		return "", 0, fmt.Errorf("unexpected dev_port %q of netdev %s: %v", attr, netdev, err)
+	}
+	return devs[0], devPort + 1, nil
+}
+
+// PortState returns the state of an RDMA device port, e.g. "ACTIVE"
+func (s *sysfs) PortState(rdmaDev string, port int) (string, error) {
+	attr, err := s.readAttr(infinibandClassDir, rdmaDev, "ports", strconv.Itoa(port), "state")
+	if err != nil {
+		return "", fmt.Errorf("failed to read state of RDMA device %s port %d: %v", rdmaDev, port, err)
+	}
+	// the state is given as "<number>: <name>", e.g. "4: ACTIVE"
+	if _, name, found := strings.Cut(attr, ":"); found {
+		return strings.TrimSpace(name), nil
+	}
+	return attr, nil
+}
+
+// PortPkeys returns the pkeys in the pkey table of an RDMA device port, empty entries are skipped
+func (s *sysfs) PortPkeys(rdmaDev string, port int) ([]uint16, error) {
+	pkeysDir := filepath.Join(infinibandClassDir, rdmaDev, "ports", strconv.Itoa(port), "pkeys")
+	indexes, err := s.list(pkeysDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pkey table of RDMA device %s port %d: %v", rdmaDev, port, err)
+	}
+
+	pkeys := make([]uint16, 0, len(indexes))
+	for _, index := range indexes {
+		attr, err := s.readAttr(pkeysDir, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pkey %s of RDMA device %s port %d: %v", index, rdmaDev, port, err)
+		}
+		pkey, err := strconv.ParseUint(attr, 0, 16)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected pkey %q of RDMA device %s port %d: %v", attr, rdmaDev, port, err)
+		}
+		if pkey&types.PkeyMask == 0 {
+			continue
+		}
+		pkeys = append(pkeys, uint16(pkey)) //nolint:gosec // parsed as 16 bits
+	}
+	return pkeys, nil
+}
+
+// isIpoibParent checks if netdev is an IPoIB netdev which is not a pkey child of another netdev
+func (s *sysfs) isIpoibParent(netdev string) bool {
+	linkType, err := s.readAttr(netClassDir, netdev, "type")
+	if err != nil || linkType != arphrdInfiniband {
+		return false
+	}
+	// the ipoib driver adds the parent attribute to child netdevs only
+	_, err = os.Stat(s.path(netClassDir, netdev, "parent"))
+	return os.IsNotExist(err)
+}
+
+func (s *sysfs) path(elem ...string) string {
+	return filepath.Join(append([]string{s.root}, elem...)...)
+}
+
+func (s *sysfs) readAttr(elem ...string) (string, error) {
+	data, err := os.ReadFile(s.path(elem...))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s *sysfs) list(elem ...string) ([]string, error) {
+	entries, err := os.ReadDir(s.path(elem...))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// portGUID returns the port GUID, the interface ID of the port GID at index 0
+func portGUID(gid string) string {
+	groups := strings.Split(gid, ":")
+	if len(groups) != gidGroups {
+		return ""
+	}
+	return normalizeGUID(strings.Join(groups[gidGroups/2:], ""))
+}
+
+// normalizeGUID returns guid as 16 lower case hex digits without separators and prefix
+func normalizeGUID(guid string) string {
+	guid = strings.ToLower(guid)
+	guid = strings.TrimPrefix(guid, "0x")
+	return strings.ReplaceAll(guid, ":", "")
+}
@@ -0,0 +1,206 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package sysfs
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeTree builds a minimal sysfs tree of a single RDMA device with IPoIB netdevs under root
+type fakeTree struct {
+	root string
+}
+
+func (f *fakeTree) write(path, content string) {
+	path = filepath.Join(f.root, path)
+	Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+	Expect(os.WriteFile(path, []byte(content+"\n"), 0o644)).To(Succeed())
+}
+
+func (f *fakeTree) symlink(target, path string) {
+	path = filepath.Join(f.root, path)
+	Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+	Expect(os.Symlink(target, path)).To(Succeed())
+}
+
+// addRdmaDevice adds an RDMA device with the given port GIDs under PCI device pciAddr
+func (f *fakeTree) addRdmaDevice(pciAddr, rdmaDev string, gids ...string) {
+	devDir := filepath.Join("devices/pci0000:00", pciAddr)
+	for i, gid := range gids {
+		portDir := filepath.Join(devDir, "infiniband", rdmaDev, "ports", strconv.Itoa(i+1))
+		f.write(filepath.Join(portDir, "gids/0"), gid)
+		f.write(filepath.Join(portDir, "state"), "4: ACTIVE")
+		f.write(filepath.Join(portDir, "pkeys/0"), "0xffff")
+		f.write(filepath.Join(portDir, "pkeys/1"), "0x0000")
+	}
+	f.symlink("../../../"+pciAddr, filepath.Join(devDir, "infiniband", rdmaDev, "device"))
+	f.symlink(filepath.Join("../../..", devDir), filepath.Join("bus/pci/devices", pciAddr))
+	f.symlink(filepath.Join("../..", devDir, "infiniband", rdmaDev), filepath.Join("class/infiniband", rdmaDev))
+}
+
+// addNetdev adds a netdev of PCI device pciAddr, parent is empty for netdevs which are not pkey children
+func (f *fakeTree) addNetdev(pciAddr, netdev, linkType string, devPort int, parent string) {
+	netDir := filepath.Join("devices/pci0000:00", pciAddr, "net", netdev)
+	f.write(filepath.Join(netDir, "type"), linkType)
+	f.write(filepath.Join(netDir, "dev_port"), strconv.Itoa(devPort))
+	if parent != "" {
+		f.write(filepath.Join(netDir, "parent"), parent)
+	}
+	f.symlink("../..", filepath.Join(netDir, "device"))
+	f.symlink(filepath.Join("../..", netDir), filepath.Join("class/net", netdev))
+}
+
+var _ = Describe("Sysfs", func() {
+	var (
+		tree *fakeTree
+		s    *sysfs
+	)
+
+	BeforeEach(func() {
+		root, err := os.MkdirTemp("", "sysfs-test-*")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(os.RemoveAll, root)
+
+		tree = &fakeTree{root: root}
+		tree.addRdmaDevice("0000:03:00.0", "mlx5_0", "fe80:0000:0000:0000:0c42:a103:0017:5a3c")
+		tree.addNetdev("0000:03:00.0", "ib0", "32", 0, "")
+		tree.addNetdev("0000:03:00.0", "ib0.8001", "32", 0, "ib0")
+		tree.addRdmaDevice("0000:04:00.0", "mlx4_0",
+			"fe80:0000:0000:0000:0002:c903:000e:0b71", "fe80:0000:0000:0000:0002:c903:000e:0b72")
+		tree.addNetdev("0000:04:00.0", "eth4", "1", 0, "")
+		tree.addNetdev("0000:04:00.0", "ib1", "32", 0, "")
+		tree.addNetdev("0000:04:00.0", "ib2", "32", 1, "")
+		s = &sysfs{root: root}
+	})
+
+	Context("Checking RdmaDevicesByPci function", func() {
+		It("Assuming existing PCI device", func() {
+			devs, err := s.RdmaDevicesByPci("0000:03:00.0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(devs).To(Equal([]string{"mlx5_0"}))
+		})
+		It("Assuming non existing PCI device", func() {
+			_, err := s.RdmaDevicesByPci("0000:05:00.0")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking NetdevByRdmaPort function", func() {
+		It("Assuming parent IPoIB netdev with pkey child", func() {
+			netdev, err := s.NetdevByRdmaPort("mlx5_0", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(netdev).To(Equal("ib0"))
+		})
+		It("Assuming dual port device", func() {
+			netdev, err := s.NetdevByRdmaPort("mlx4_0", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(netdev).To(Equal("ib2"))
+		})
+		It("Assuming non existing port", func() {
+			_, err := s.NetdevByRdmaPort("mlx5_0", 2)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming non existing RDMA device", func() {
+			_, err := s.NetdevByRdmaPort("mlx5_9", 1)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking RdmaPortByNetdev function", func() {
+		It("Assuming IPoIB netdev", func() {
+			dev, port, err := s.RdmaPortByNetdev("ib2")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dev).To(Equal("mlx4_0"))
+			Expect(port).To(Equal(2))
+		})
+		It("Assuming pkey child netdev", func() {
+			dev, port, err := s.RdmaPortByNetdev("ib0.8001")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dev).To(Equal("mlx5_0"))
+			Expect(port).To(Equal(1))
+		})
+		It("Assuming non existing netdev", func() {
+			_, _, err := s.RdmaPortByNetdev("ib9")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking PortState function", func() {
+		It("Assuming active port", func() {
+			Expect(s.PortState("mlx4_0", 2)).To(Equal(PortStateActive))
+		})
+		It("Assuming down port", func() {
+			tree.write("class/infiniband/mlx4_0/ports/1/state", "1: DOWN")
+			Expect(s.PortState("mlx4_0", 1)).To(Equal("DOWN"))
+		})
+		It("Assuming non existing port", func() {
+			_, err := s.PortState("mlx4_0", 3)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking PortPkeys function", func() {
+		It("Assuming default pkey table", func() {
+			Expect(s.PortPkeys("mlx5_0", 1)).To(Equal([]uint16{0xffff}))
+		})
+		It("Assuming pkey table with partitions", func() {
+			tree.write("class/infiniband/mlx5_0/ports/1/pkeys/1", "0x8001")
+			tree.write("class/infiniband/mlx5_0/ports/1/pkeys/2", "0x0002")
+			Expect(s.PortPkeys("mlx5_0", 1)).To(ConsistOf(uint16(0xffff), uint16(0x8001), uint16(0x0002)))
+		})
+		It("Assuming malformed pkey", func() {
+			tree.write("class/infiniband/mlx5_0/ports/1/pkeys/1", "invalid")
+			_, err := s.PortPkeys("mlx5_0", 1)
+			Expect(err).To(HaveOccurred())
+		})
+		It("Assuming non existing port", func() {
+			_, err := s.PortPkeys("mlx5_0", 2)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Checking PortGUID function", func() {
+		It("Assuming existing port", func() {
+			Expect(s.PortGUID("mlx5_0", 1)).To(Equal("0c42a10300175a3c"))
+		})
+		It("Assuming non existing port", func() {
+			_, err := s.PortGUID("mlx5_0", 3)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+	Context("Checking RdmaPortByGUID function", func() {
+		DescribeTable("Assuming existing port GUID",
+			func(guid, expectedDev string, expectedPort int) {
+				dev, port, err := s.RdmaPortByGUID(guid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dev).To(Equal(expectedDev))
+				Expect(port).To(Equal(expectedPort))
+			},
+			Entry("colon separated", "0c42:a103:0017:5a3c", "mlx5_0", 1),
+			Entry("hex with prefix", "0x0002C903000E0B72", "mlx4_0", 2),
+		)
+		It("Assuming non existing port GUID", func() {
+			_, _, err := s.RdmaPortByGUID("0x0002c903000e0b73")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -10,6 +10,29 @@ type NetlinkManager struct {
 	mock.Mock
 }
 
+// AddrList provides a mock function with given fields: link, family
+func (_m *NetlinkManager) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	ret := _m.Called(link, family)
+
+	var r0 []netlink.Addr
+	if rf, ok := ret.Get(0).(func(netlink.Link, int) []netlink.Addr); ok {
+		r0 = rf(link, family)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]netlink.Addr)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(netlink.Link, int) error); ok {
+		r1 = rf(link, family)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // LinkAdd provides a mock function with given fields: link
 func (_m *NetlinkManager) LinkAdd(link netlink.Link) error {
 	ret := _m.Called(link)
@@ -61,6 +84,29 @@ func (_m *NetlinkManager) LinkDel(link netlink.Link) error {
 	return r0
 }
 
+// LinkList provides a mock function with given fields:
+func (_m *NetlinkManager) LinkList() ([]netlink.Link, error) {
+	ret := _m.Called()
+
+	var r0 []netlink.Link
+	if rf, ok := ret.Get(0).(func() []netlink.Link); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]netlink.Link)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // LinkSetMTU provides a mock function with given fields: _a0, _a1
 func (_m *NetlinkManager) LinkSetMTU(_a0 netlink.Link, _a1 int) error {
 	ret := _m.Called(_a0, _a1)
@@ -75,6 +121,20 @@ func (_m *NetlinkManager) LinkSetMTU(_a0 netlink.Link, _a1 int) error {
 	return r0
 }
 
+// LinkSetAlias provides a mock function with given fields: _a0, _a1
+func (_m *NetlinkManager) LinkSetAlias(_a0 netlink.Link, _a1 string) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(netlink.Link, string) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // LinkSetDown provides a mock function with given fields: _a0
 func (_m *NetlinkManager) LinkSetDown(_a0 netlink.Link) error {
 	ret := _m.Called(_a0)
@@ -17,23 +17,237 @@
 package types
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/vishvananda/netlink"
 )
 
+// ErrPolicyViolation is the CNI error code returned when the tenant policy does not allow the
+// netconf, codes below 100 are reserved by the CNI spec
+const ErrPolicyViolation uint = 100
+
+// ErrAlreadyExists is the CNI error code returned by ADD when another interface has the name of the
+// attachment in the pod, the CNI spec defines no code for it
+const ErrAlreadyExists uint = 101
+
+// ErrPluginNotAvailable is the CNI error code returned by STATUS when the plugin cannot serve ADD
+// requests, defined by the CNI spec but not by the cni library
+const ErrPluginNotAvailable uint = 50
+
+const (
+	// MaxIfNameLen is the maximal length of a netdev name, IFNAMSIZ without the terminating null
+	MaxIfNameLen = 15
+
+	// PkeyMask masks the 15 bit partition key out of a pkey
+	PkeyMask = 0x7fff
+	// PkeyFullMemberBit is the pkey bit set for full members of a partition
+	PkeyFullMemberBit = 0x8000
+	// MaxPkey is the largest pkey value, including the membership bit
+	MaxPkey = 0xffff
+
+	// PkeyMembershipFull makes the child link a full member of the partition
+	PkeyMembershipFull = "full"
+	// PkeyMembershipLimited makes the child link a limited member of the partition
+	PkeyMembershipLimited = "limited"
+	// PkeyMembershipInherit makes the child link use the partition membership of the master
+	PkeyMembershipInherit = "inherit"
+
+	// MasterSelectionFailover picks the first master of the pool with an active port
+	MasterSelectionFailover = "failover"
+	// MasterSelectionLeastLoaded picks the master of the pool with an active port and the fewest children
+	MasterSelectionLeastLoaded = "leastLoaded"
+
+	// IpoibModeDatagram is the IPoIB datagram (UD) mode
+	IpoibModeDatagram = "datagram"
+	// IpoibModeConnected is the IPoIB connected (RC) mode
+	IpoibModeConnected = "connected"
+
+	// MaxDatagramMTU is the largest MTU in datagram mode, the 4K IB MTU without the 4 byte IPoIB header
+	MaxDatagramMTU = 4092
+	// MaxConnectedMTU is the largest MTU in connected mode
+	MaxConnectedMTU = 65520
+
+	// MTUPolicyStrict fails when the MTU exceeds the limit of the master and mode
+	MTUPolicyStrict = "strict"
+	// MTUPolicyClamp lowers the MTU to the limit of the master and mode
+	MTUPolicyClamp = "clamp"
+)
+
 // NetConf extends cni NetConf
 type NetConf struct {
 	types.NetConf
 	Master string `json:"master"`
-	MTU    int    `json:"mtu,omitempty"`
+	// Masters is a pool of masters to pick from as an alternative to a single master, MasterSelection
+	// is either "failover" to pick the first one with an active port or "leastLoaded"
+	Masters         []string `json:"masters,omitempty"`
+	MasterSelection string   `json:"masterSelection,omitempty"`
+	// MasterPci, MasterRdmaDevice with MasterPort and MasterPortGUID select the master by its
+	// InfiniBand device as an alternative to the netdev name
+	MasterPci        string `json:"masterPci,omitempty"`
+	MasterRdmaDevice string `json:"masterRdmaDevice,omitempty"`
+	MasterPort       int    `json:"masterPort,omitempty"`
+	MasterPortGUID   string `json:"masterPortGuid,omitempty"`
+	// DeviceID is the PCI address of the HCA allocated to the pod by a device plugin, set by Multus
+	DeviceID      string         `json:"deviceID,omitempty"`
+	RuntimeConfig *RuntimeConfig `json:"runtimeConfig,omitempty"`
+	MTU           int            `json:"mtu,omitempty"`
+	// MTUPolicy is either "strict" to fail or "clamp" to lower an MTU above the limit of the master and
+	// mode, defaults to "strict"
+	MTUPolicy string `json:"mtuPolicy,omitempty"`
+	// AutoCreateParent creates the host pkey child of the master for Pkey if missing, and creates the
+	// child link from it
+	AutoCreateParent bool `json:"autoCreateParent,omitempty"`
+	// MaxChildren limits the number of IPoIB children of the master, 0 means no limit
+	MaxChildren int   `json:"maxChildren,omitempty"`
+	Pkey        *Pkey `json:"pkey,omitempty"`
+	// AllowPodAnnotations lets the pod annotations override the settings of the network, which the
+	// tenants owning the pods may set
+	AllowPodAnnotations bool `json:"allowPodAnnotations,omitempty"`
+	// Partition names a partition of the node partitions file to take the pkey and membership from
+	Partition string `json:"partition,omitempty"`
+	// PkeyMembership is one of "full", "limited" or "inherit", if empty the pkey is used as is
+	PkeyMembership string `json:"pkeyMembership,omitempty"`
+	// PortMemberships maps the port GUIDs listed in the partition to their membership, which replaces
+	// PkeyMembership for the child of the master with the port GUID
+	PortMemberships map[string]string `json:"-"`
+	// Mode is the IPoIB mode of the child link, "datagram" or "connected", if empty the master mode is used
+	Mode string `json:"mode,omitempty"`
+	// Umcast allows the child link to send and receive user-level multicast, defaults to true
+	Umcast *bool `json:"umcast,omitempty"`
+	// IpoibAttrs sets the IPoIB netlink attributes of the child link, a structured alternative
+	// to the pkey, mode and umcast fields
+	IpoibAttrs *IpoibAttrs `json:"ipoibAttrs,omitempty"`
+	// LogLevel is one of "debug", "info", "warning" or "error"
+	LogLevel string `json:"logLevel,omitempty"`
+	// LogFile is where the logs go instead of stderr, only set from the node defaults file as the netconf
+	// may be written by tenants
+	LogFile string `json:"-"`
+	// DefaultsFile is the node defaults file to use instead of the default one, in the same directory
+	DefaultsFile string `json:"defaultsFile,omitempty"`
+	// Policy is the tenant policy of the pod namespace, set from the node policy file
+	Policy *TenantPolicy `json:"-"`
+	// UnknownFields are the paths of the netconf fields the plugin does not define, e.g. added by meta
+	// plugins, they are ignored
+	UnknownFields []string `json:"-"`
+}
+
+// TenantPolicy restricts the masters, pkeys and MTU a Kubernetes namespace may use, unset fields do
+// not restrict
+type TenantPolicy struct {
+	Namespace string   `json:"-"`
+	Masters   []string `json:"masters,omitempty"`
+	// Pkeys are compared without the membership bit
+	Pkeys []Pkey    `json:"pkeys,omitempty"`
+	MTU   *MTURange `json:"mtu,omitempty"`
+}
+
+// MTURange is an inclusive MTU range, a zero bound is not checked
+type MTURange struct {
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+}
+
+// RuntimeConfig holds the capability arguments passed by the runtime
+type RuntimeConfig struct {
+	DeviceID string `json:"deviceID,omitempty"`
+	// Ipoib overrides the netconf settings for a single attachment
+	Ipoib *Overrides `json:"ipoib,omitempty"`
+	// PodAnnotations are the annotations of the pod, passed by the runtime when configured to
+	PodAnnotations map[string]string `json:"io.kubernetes.cri.pod-annotations,omitempty"`
+}
+
+// Overrides holds the netconf settings which can be overridden per pod
+type Overrides struct {
+	Pkey *Pkey  `json:"pkey,omitempty"`
+	MTU  int    `json:"mtu,omitempty"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// IpoibAttrs holds the IFLA_IPOIB_* netlink attributes of an IPoIB link
+type IpoibAttrs struct {
+	Pkey   *Pkey  `json:"pkey,omitempty"`
+	Mode   string `json:"mode,omitempty"`
+	Umcast *bool  `json:"umcast,omitempty"`
+}
+
+// IpoibLink describes an IPoIB child link and the settings it was created with
+type IpoibLink struct {
+	Interface      *current.Interface `json:"-"`
+	Pkey           Pkey               `json:"pkey"`
+	PkeyMembership string             `json:"pkeyMembership"`
+	Mode           string             `json:"mode"`
+	Umcast         bool               `json:"umcast"`
+	MTU            int                `json:"mtu"`
+	// Master is the parent of the link, Index its index in the pod network namespace and TempName its
+	// name in the host network namespace before it was moved
+	Master   string `json:"-"`
+	Index    int    `json:"-"`
+	TempName string `json:"-"`
+	// IPs are the addresses of a child reused from a previous ADD, reported instead of running IPAM again
+	IPs []*current.IPConfig `json:"-"`
+}
+
+// Pkey is an InfiniBand partition key. In the netconf it can be given either as a
+// JSON number or as a string holding a decimal or "0x" prefixed hexadecimal value.
+type Pkey int
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Pkey) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		var num int
+		if err = json.Unmarshal(data, &num); err != nil {
+			return fmt.Errorf("pkey must be a number or a string, got %s", string(data))
+		}
+		*p = Pkey(num)
+		return nil
+	}
+	return p.UnmarshalText([]byte(str))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, used for pkeys given in CNI_ARGS
+func (p *Pkey) UnmarshalText(text []byte) error {
+	num, err := strconv.ParseInt(strings.TrimSpace(string(text)), 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid pkey %q: %v", string(text), err)
+	}
+	*p = Pkey(num)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (p Pkey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// String returns the pkey in the hexadecimal notation used by the kernel
+func (p Pkey) String() string {
+	return fmt.Sprintf("0x%04x", int(p))
 }
 
 // Manager provides interface invoke ipoib nic related operations
 type Manager interface {
-	CreateIpoibLink(conf *NetConf, ifName string, netns ns.NetNS) (*current.Interface, error)
+	CreateIpoibLink(conf *NetConf, containerID, ifName string, netns ns.NetNS, journal Journal) (*IpoibLink, error)
+	ReuseIpoibLink(conf *NetConf, ifName string, netns ns.NetNS, recordedMaster string) (*IpoibLink, error)
 	RemoveIpoibLink(ifName string, netns ns.NetNS) error
+	RemoveIpoibLinkWithIndex(ifName string, index int, netns ns.NetNS) error
+	CheckMaster(conf *NetConf) error
+	GarbageCollect(conf *NetConf, valid []types.GCAttachment) error
+	RemoveHostLink(tempName string) error
+}
+
+// Journal records the steps of ADD before they are taken, so that an interrupted ADD can be rolled back
+type Journal interface {
+	// RecordLink records that the child link is about to be created with the temporary name
+	RecordLink(tempName string) error
+	// RecordIndex records the index of the child link moved to the pod network namespace, before it is renamed
+	RecordIndex(index int) error
 }
 
 // NetlinkManager is an interface to mock nelink library
@@ -45,6 +259,20 @@ type NetlinkManager interface {
 	LinkSetNsFd(netlink.Link, int) error
 	LinkAdd(link netlink.Link) error
 	LinkDel(link netlink.Link) error
+	LinkList() ([]netlink.Link, error)
+	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
 	LinkSetMTU(link netlink.Link, mtu int) error
+	LinkSetAlias(link netlink.Link, alias string) error
 	SetSysVal(attribute, value string) (string, error)
 }
+
+// SysfsManager is an interface to query InfiniBand devices in sysfs
+type SysfsManager interface {
+	RdmaDevicesByPci(pciAddr string) ([]string, error)
+	NetdevByRdmaPort(rdmaDev string, port int) (string, error)
+	RdmaPortByGUID(guid string) (string, int, error)
+	RdmaPortByNetdev(netdev string) (string, int, error)
+	PortGUID(rdmaDev string, port int) (string, error)
+	PortState(rdmaDev string, port int) (string, error)
+	PortPkeys(rdmaDev string, port int) ([]uint16, error)
+}
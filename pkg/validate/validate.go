@@ -0,0 +1,243 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	cniversion "github.com/containernetworking/cni/pkg/version"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/Mellanox/ipoib-cni/pkg/config"
+	"github.com/Mellanox/ipoib-cni/pkg/types"
+)
+
+// Severities of the findings
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+const (
+	pluginType = "ipoib"
+	nadKind    = "NetworkAttachmentDefinition"
+)
+
+// Finding is a problem found in a network configuration
+type Finding struct {
+	Source string `json:"source"`
+	// Document is the index of the document in a multi document YAML source
+	Document int `json:"document"`
+	// Network is the name of the network, if known
+	Network string `json:"network,omitempty"`
+	// Field is the path of the field in the document, e.g. "spec.config.plugins[0].mtu"
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// HasErrors returns true if any of the findings is an error
+func HasErrors(findings []*Finding) bool {
+	return slices.ContainsFunc(findings, func(f *Finding) bool { return f.Severity == SeverityError })
+}
+
+// validator collects the findings of a single document
+type validator struct {
+	source   string
+	document int
+	network  string
+	findings []*Finding
+}
+
+func (v *validator) add(severity, field, format string, args ...any) {
+	v.findings = append(v.findings, &Finding{
+		Source:   v.source,
+		Document: v.document,
+		Network:  v.network,
+		Field:    field,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate validates the IPoIB network configurations in data, which holds a netconf, a conflist or
+// NetworkAttachmentDefinition manifests, in JSON or YAML. The validation runs offline, without
+// reading the node files, and returns the problems found.
+func Validate(source string, data []byte) ([]*Finding, error) {
+	var findings []*Finding
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var doc any
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", source, err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		v := &validator{source: source, document: i}
+		v.validateDocument(doc)
+		findings = append(findings, v.findings...)
+	}
+	return findings, nil
+}
+
+// validateDocument validates a NetworkAttachmentDefinition, a conflist or a netconf
+func (v *validator) validateDocument(doc any) {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		v.add(SeverityError, "", "expected an object, got %T", doc)
+		return
+	}
+	if obj["kind"] != nadKind {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			v.add(SeverityError, "", "failed to convert to JSON: %v", err)
+			return
+		}
+		v.validateConfig("", data)
+		return
+	}
+
+	spec, _ := obj["spec"].(map[string]any)
+	if metadata, ok := obj["metadata"].(map[string]any); ok {
+		v.network, _ = metadata["name"].(string)
+	}
+	cfg, ok := spec["config"].(string)
+	if !ok || cfg == "" {
+		v.add(SeverityWarning, "spec.config", "missing, the network configuration is read from the nodes")
+		return
+	}
+	v.validateConfig("spec.config", []byte(cfg))
+}
+
+// validateConfig validates the IPoIB plugins of a conflist, or a netconf
+func (v *validator) validateConfig(prefix string, data []byte) {
+	conf := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		v.add(SeverityError, prefix, "invalid JSON: %v", err)
+		return
+	}
+
+	if _, ok := conf["plugins"]; !ok {
+		var typ string
+		_ = json.Unmarshal(conf["type"], &typ)
+		if typ != pluginType {
+			v.add(SeverityWarning, join(prefix, "type"), "%q is not an IPoIB network, not validated", typ)
+			return
+		}
+		v.validateNetconf(prefix, data)
+		return
+	}
+
+	list := struct {
+		CNIVersion string                       `json:"cniVersion"`
+		Name       string                       `json:"name"`
+		Plugins    []map[string]json.RawMessage `json:"plugins"`
+	}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		v.add(SeverityError, prefix, "invalid conflist: %v", err)
+		return
+	}
+
+	found := false
+	for i, plugin := range list.Plugins {
+		var typ string
+		_ = json.Unmarshal(plugin["type"], &typ)
+		if typ != pluginType {
+			continue
+		}
+		found = true
+
+		// the runtime sets the name and version of the list on each plugin
+		plugin["name"], _ = json.Marshal(list.Name)
+		plugin["cniVersion"], _ = json.Marshal(list.CNIVersion)
+		pluginData, err := json.Marshal(plugin)
+		if err != nil {
+			v.add(SeverityError, join(prefix, fmt.Sprintf("plugins[%d]", i)), "invalid plugin: %v", err)
+			continue
+		}
+		v.validateNetconf(join(prefix, fmt.Sprintf("plugins[%d]", i)), pluginData)
+	}
+	if !found {
+		v.add(SeverityWarning, join(prefix, "plugins"), "no IPoIB plugin, not validated")
+	}
+}
+
+// validateNetconf validates an IPoIB netconf like the plugin does, and checks for settings which are valid
+// but likely to fail or misbehave on the nodes
+func (v *validator) validateNetconf(prefix string, data []byte) {
+	n, fieldErrors := config.ValidateConf(data)
+	if n != nil && v.network == "" {
+		v.network = n.Name
+	}
+	for _, f := range fieldErrors {
+		v.add(SeverityError, join(prefix, f.Field), "%s", f.Message)
+	}
+	if n == nil {
+		return
+	}
+
+	if n.Name == "" {
+		v.add(SeverityError, join(prefix, "name"), "missing")
+	}
+	if n.CNIVersion == "" {
+		v.add(SeverityWarning, join(prefix, "cniVersion"), "missing, the runtime assumes version 0.1.0")
+	} else if !slices.Contains(cniversion.All.SupportedVersions(), n.CNIVersion) {
+		v.add(SeverityError, join(prefix, "cniVersion"), "version %q is not supported", n.CNIVersion)
+	}
+	if n.IPAM.Type == "dhcp" {
+		v.add(SeverityError, join(prefix, "ipam.type"), "dhcp IPAM is not supported")
+	}
+	if n.Mode == types.IpoibModeDatagram && n.MTU > types.MaxDatagramMTU {
+		if n.MTUPolicy == types.MTUPolicyClamp {
+			v.add(SeverityWarning, join(prefix, "mtu"), "%d exceeds the datagram mode limit %d, clamped on the nodes",
+				n.MTU, types.MaxDatagramMTU)
+		} else {
+			v.add(SeverityError, join(prefix, "mtu"), "%d exceeds the datagram mode limit %d", n.MTU,
+				types.MaxDatagramMTU)
+		}
+	}
+	if n.PkeyMembership == types.PkeyMembershipLimited {
+		v.add(SeverityWarning, join(prefix, "pkeyMembership"), "pods join the partition as limited members, they can "+
+			"only reach full members of the partition")
+	}
+	if n.Partition != "" {
+		v.add(SeverityWarning, join(prefix, "partition"), "%q is resolved on each node from %s, not validated",
+			n.Partition, config.PartitionsFile)
+	}
+}
+
+// join joins a path prefix and a field path
+func join(prefix, field string) string {
+	switch {
+	case prefix == "":
+		return field
+	case field == "":
+		return prefix
+	}
+	return prefix + "." + field
+}
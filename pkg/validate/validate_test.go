@@ -0,0 +1,107 @@
+// Copyright 2026 NVIDIA CORPORATION & AFFILIATES
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const validNetconf = `{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0", "pkey": "0x8001",
+	"ipam": {"type": "host-local", "subnet": "192.168.2.0/24"}}`
+
+const nadManifests = `apiVersion: k8s.cni.cncf.io/v1
+kind: NetworkAttachmentDefinition
+metadata:
+  name: storage
+spec:
+  config: '{"cniVersion": "1.0.0", "name": "storage", "type": "ipoib", "master": "ib0", "mtu": 70000}'
+---
+apiVersion: k8s.cni.cncf.io/v1
+kind: NetworkAttachmentDefinition
+metadata:
+  name: mpi
+spec:
+  config: |
+    {
+      "cniVersion": "1.0.0",
+      "name": "mpi",
+      "plugins": [
+        {"type": "ipoib", "masters": ["ib0", "ib1"], "mode": "datagram", "mtu": 65520},
+        {"type": "tuning", "sysctl": {"net.core.somaxconn": "500"}}
+      ]
+    }
+`
+
+var _ = Describe("Validate", func() {
+	It("reports nothing for a valid netconf", func() {
+		findings, err := Validate("net.json", []byte(validNetconf))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(BeEmpty())
+		Expect(HasErrors(findings)).To(BeFalse())
+	})
+	It("reports the findings of each NetworkAttachmentDefinition", func() {
+		findings, err := Validate("nads.yaml", []byte(nadManifests))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(ConsistOf(
+			&Finding{Source: "nads.yaml", Document: 0, Network: "storage", Field: "spec.config.mtu",
+				Severity: SeverityError, Message: "must be in range 68-65520, got 70000"},
+			&Finding{Source: "nads.yaml", Document: 1, Network: "mpi", Field: "spec.config.plugins[0].mtu",
+				Severity: SeverityError, Message: "65520 exceeds the datagram mode limit 4092"},
+		))
+		Expect(HasErrors(findings)).To(BeTrue())
+	})
+	It("validates a netconf in YAML", func() {
+		findings, err := Validate("net.yaml", []byte("cniVersion: 1.0.0\nname: mynet\ntype: ipoib\nmaster: ib0\n"+
+			"pkey: 0x0001\nmtus: 2044\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(findings).To(ConsistOf(HaveField("Field", "mtus")))
+	})
+	DescribeTable("reports the finding",
+		func(conf, field, severity string) {
+			findings, err := Validate("net.json", []byte(conf))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findings).To(ContainElement(And(HaveField("Field", field), HaveField("Severity", severity))))
+		},
+		Entry("missing name", `{"cniVersion": "1.0.0", "type": "ipoib", "master": "ib0"}`, "name", SeverityError),
+		Entry("missing cniVersion", `{"name": "mynet", "type": "ipoib", "master": "ib0"}`, "cniVersion",
+			SeverityWarning),
+		Entry("unsupported cniVersion", `{"cniVersion": "9.0.0", "name": "mynet", "type": "ipoib", "master": "ib0"}`,
+			"cniVersion", SeverityError),
+		Entry("dhcp ipam", `{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0",
+			"ipam": {"type": "dhcp"}}`, "ipam.type", SeverityError),
+		Entry("clamped datagram mtu", `{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0",
+			"mode": "datagram", "mtu": 9000, "mtuPolicy": "clamp"}`, "mtu", SeverityWarning),
+		Entry("limited membership", `{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0",
+			"pkeyMembership": "limited"}`, "pkeyMembership", SeverityWarning),
+		Entry("partition", `{"cniVersion": "1.0.0", "name": "mynet", "type": "ipoib", "master": "ib0",
+			"partition": "storage"}`, "partition", SeverityWarning),
+		Entry("other plugin", `{"cniVersion": "1.0.0", "name": "mynet", "type": "bridge"}`, "type",
+			SeverityWarning),
+		Entry("conflist without ipoib plugin", `{"cniVersion": "1.0.0", "name": "mynet",
+			"plugins": [{"type": "bridge"}]}`, "plugins", SeverityWarning),
+		Entry("not an object", `["ipoib"]`, "", SeverityError),
+		Entry("invalid NetworkAttachmentDefinition config", "kind: NetworkAttachmentDefinition\n"+
+			"spec:\n  config: '{'\n", "spec.config", SeverityError),
+		Entry("missing NetworkAttachmentDefinition config", "kind: NetworkAttachmentDefinition\nspec: {}\n",
+			"spec.config", SeverityWarning),
+	)
+	It("fails on invalid YAML", func() {
+		_, err := Validate("net.yaml", []byte("name: [mynet"))
+		Expect(err).To(HaveOccurred())
+	})
+})